@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+	"github.com/ulikunitz/xz"
+)
+
+// Limits restrict content of extracted archives, so malicious archives can't
+// exhaust disk space. Zero values mean no limit.
+type Limits struct {
+	// MaxTotalSize is the maximum total size of extracted files in bytes.
+	MaxTotalSize int64
+	// MaxFileSize is the maximum size of a single extracted file in bytes.
+	MaxFileSize int64
+	// MaxEntries is the maximum number of entries in the archive.
+	MaxEntries int
+}
+
+// DefaultLimits are limits used if they aren't configured by the user.
+var DefaultLimits = Limits{
+	MaxTotalSize: 1 << 30,   // 1 GiB
+	MaxFileSize:  512 << 20, // 512 MiB
+	MaxEntries:   10000,
+}
+
+// PolicyViolationError is returned when an archive entry can't be extracted
+// safely.
+type PolicyViolationError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("archive entry %q violates extraction policy: %s", e.Entry, e.Reason)
+}
+
+// Source is a downloaded archive. Random access is required by zip archives.
+type Source interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// Extract extracts archive of the given format into specified directory.
+// Entries with paths leading outside the directory or exceeding limits are
+// rejected. Symbolic links have to point to files within the directory.
+func Extract(src Source, format Format, fs afero.Fs, outputDir string, limits Limits) error {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatTar:
+		return extractTar(src, fs, outputDir, limits)
+	case FormatTarGz:
+		uncompressedStream, err := gzip.NewReader(src)
+		if err != nil {
+			return err
+		}
+		return extractTar(uncompressedStream, fs, outputDir, limits)
+	case FormatTarXz:
+		uncompressedStream, err := xz.NewReader(bufio.NewReader(src))
+		if err != nil {
+			return err
+		}
+		return extractTar(uncompressedStream, fs, outputDir, limits)
+	case FormatTarZst:
+		uncompressedStream, err := zstd.NewReader(src)
+		if err != nil {
+			return err
+		}
+		defer uncompressedStream.Close()
+		return extractTar(uncompressedStream, fs, outputDir, limits)
+	case FormatZip:
+		size, err := src.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		return extractZip(src, size, fs, outputDir, limits)
+	default:
+		return fmt.Errorf("cannot extract archive of format %q", format)
+	}
+}
+
+// WriteBinary stores a bare executable under the specified path.
+func WriteBinary(src Source, fs afero.Fs, path string, limits Limits) error {
+	size, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	e := newExtractor(fs, filepath.Dir(path), limits)
+	if err := e.addSize(filepath.Base(path), size); err != nil {
+		return err
+	}
+	return e.writeFile(path, os.FileMode(0o755), src)
+}
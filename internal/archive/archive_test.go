@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+	"github.com/ulikunitz/xz"
+)
+
+var formatTestEntries = []testEntry{
+	{"command.yaml", tar.TypeReg, "binPath: bin/cmd", ""},
+	{"libexec/cmd", tar.TypeReg, "#!/bin/sh", ""},
+	{"bin/cmd", tar.TypeSymlink, "", "../libexec/cmd"},
+}
+
+func TestExtractFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		create func(t *testing.T) []byte
+	}{
+		{FormatTar, func(t *testing.T) []byte {
+			return createTar(t, formatTestEntries)
+		}},
+		{FormatTarXz, func(t *testing.T) []byte {
+			var buf bytes.Buffer
+			writer, _ := xz.NewWriter(&buf)
+			_, _ = writer.Write(createTar(t, formatTestEntries))
+			_ = writer.Close()
+			return buf.Bytes()
+		}},
+		{FormatTarZst, func(t *testing.T) []byte {
+			var buf bytes.Buffer
+			writer, _ := zstd.NewWriter(&buf)
+			_, _ = writer.Write(createTar(t, formatTestEntries))
+			_ = writer.Close()
+			return buf.Bytes()
+		}},
+		{FormatZip, func(t *testing.T) []byte {
+			var buf bytes.Buffer
+			writer := zip.NewWriter(&buf)
+			for _, e := range formatTestEntries {
+				header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+				header.SetMode(0o755)
+				content := e.content
+				if e.typeflag == tar.TypeSymlink {
+					header.SetMode(os.ModeSymlink | 0o777)
+					content = e.linkname
+				}
+				w, err := writer.CreateHeader(header)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, _ = w.Write([]byte(content))
+			}
+			_ = writer.Close()
+			return buf.Bytes()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			outputDir := t.TempDir()
+			fs := afero.NewOsFs()
+			if err := Extract(bytes.NewReader(tt.create(t)), tt.format, fs, outputDir, DefaultLimits); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			for _, e := range formatTestEntries[:2] {
+				content, err := afero.ReadFile(fs, filepath.Join(outputDir, e.name))
+				if err != nil || string(content) != e.content {
+					t.Errorf("%s has content %q, error = %v", e.name, content, err)
+				}
+			}
+			if target, err := os.Readlink(filepath.Join(outputDir, "bin/cmd")); err != nil || target != "../libexec/cmd" {
+				t.Errorf("bin/cmd should be a symbolic link to ../libexec/cmd, got %q (error = %v)", target, err)
+			}
+		})
+	}
+}
+
+func TestWriteBinary(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	binary := bytes.NewReader([]byte("#!/bin/sh"))
+
+	if err := WriteBinary(binary, fs, "/out/cmd", DefaultLimits); err != nil {
+		t.Fatalf("WriteBinary() error = %v", err)
+	}
+	if info, err := fs.Stat("/out/cmd"); err != nil || info.Size() != 9 {
+		t.Errorf("WriteBinary() created %v, error = %v", info, err)
+	}
+
+	var policyErr *PolicyViolationError
+	if err := WriteBinary(binary, fs, "/out/big", Limits{MaxFileSize: 5}); !errors.As(err, &policyErr) {
+		t.Errorf("WriteBinary() error = %v, want PolicyViolationError", err)
+	}
+}
@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// CreateTarGz writes content of the directory to a gzipped tarball. Archives
+// are reproducible: entries are sorted, timestamps and owners are zeroed and
+// permissions are normalized to 0755 (directories and executables) or 0644.
+// The binPath file (relative to dir) is always executable, since permission
+// bits aren't available on every OS. Entries for which skip returns true
+// aren't included. Symbolic links which
+// couldn't be extracted (pointing outside of the directory) are rejected.
+func CreateTarGz(fs afero.Fs, dir string, out io.Writer, binPath string, skip func(relPath string, info os.FileInfo) bool) error {
+	walk := func(fn func(filePath string, relPath string, info os.FileInfo) error) error {
+		return afero.Walk(fs, dir, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(dir, filePath)
+			if err != nil || relPath == "." {
+				return err
+			}
+			if skip != nil && skip(relPath, info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return fn(filePath, relPath, info)
+		})
+	}
+
+	// links are checked the same way as during extraction, which requires
+	// knowing all of them in advance
+	symlinks := map[string]bool{}
+	err := walk(func(filePath string, relPath string, info os.FileInfo) error {
+		if info.Mode()&os.ModeSymlink != 0 {
+			symlinks[relPath] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for relPath := range symlinks {
+		target, err := readlink(fs, filepath.Join(dir, relPath))
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(target) || strings.HasPrefix(target, "/") || !isConfined(symlinks, filepath.Dir(relPath), target) {
+			return &PolicyViolationError{filepath.ToSlash(relPath), fmt.Sprintf("symbolic link points outside of the directory %q", target)}
+		}
+	}
+
+	gzipWriter, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = walk(func(filePath string, relPath string, info os.FileInfo) error {
+		executable := binPath != "" && relPath == filepath.Clean(binPath)
+		return addEntry(fs, tarWriter, filePath, filepath.ToSlash(relPath), info, executable)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func addEntry(fs afero.Fs, tarWriter *tar.Writer, filePath string, name string, info os.FileInfo, executable bool) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		ModTime: time.Unix(0, 0),
+	}
+
+	switch {
+	case info.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+		header.Mode = 0o755
+	case info.Mode()&os.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		header.Mode = 0o777
+		target, err := readlink(fs, filePath)
+		if err != nil {
+			return err
+		}
+		header.Linkname = filepath.ToSlash(target)
+	case info.Mode().IsRegular():
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+		if executable || info.Mode()&0o111 != 0 {
+			header.Mode = 0o755
+		}
+	default:
+		// sockets, devices etc. aren't supported by extraction anyway
+		return nil
+	}
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	file, err := fs.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
+
+func readlink(fs afero.Fs, filePath string) (string, error) {
+	linkReader, ok := fs.(afero.LinkReader)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: filePath, Err: afero.ErrNoReadlink}
+	}
+	return linkReader.ReadlinkIfPossible(filePath)
+}
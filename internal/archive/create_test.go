@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestCreateTarGz(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "/cmd/command.yaml", []byte("binPath: bin/cmd"), 0o600)
+	_ = afero.WriteFile(fs, "/cmd/bin/cmd", []byte("#!/bin/sh"), 0o700)
+	_ = afero.WriteFile(fs, "/cmd/dist/cmd.tar.gz", []byte("previous archive"), 0o644)
+	skipDist := func(relPath string, _ os.FileInfo) bool { return relPath == "dist" }
+
+	var first bytes.Buffer
+	if err := CreateTarGz(fs, "/cmd", &first, "bin/cmd", skipDist); err != nil {
+		t.Fatalf("CreateTarGz() error = %v", err)
+	}
+
+	// archives have to be identical regardless of modification times
+	_ = fs.Chtimes("/cmd/command.yaml", time.Now(), time.Now().Add(time.Hour))
+	var second bytes.Buffer
+	if err := CreateTarGz(fs, "/cmd", &second, "bin/cmd", skipDist); err != nil {
+		t.Fatalf("CreateTarGz() error = %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("CreateTarGz() created different archives for the same content")
+	}
+
+	outputFs := afero.NewMemMapFs()
+	if err := Extract(bytes.NewReader(first.Bytes()), FormatTarGz, outputFs, "/out", DefaultLimits); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	tests := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{"/out/command.yaml", 0o644},
+		{"/out/bin/cmd", 0o755},
+	}
+	for _, tt := range tests {
+		info, err := outputFs.Stat(tt.path)
+		if err != nil {
+			t.Errorf("%s not found in the archive: %v", tt.path, err)
+		} else if info.Mode().Perm() != tt.mode || !info.ModTime().Equal(time.Unix(0, 0)) {
+			t.Errorf("%s has mode %s and mtime %s, want %s and the Unix epoch", tt.path, info.Mode().Perm(), info.ModTime(), tt.mode)
+		}
+	}
+	if _, err := outputFs.Stat("/out/dist"); err == nil {
+		t.Errorf("skipped directory found in the archive")
+	}
+}
+
+func TestCreateTarGzSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		target  string
+		wantErr bool
+	}{
+		{"Confined", "bin/cmd", "../libexec/cmd", false},
+		{"Absolute", "bin/passwd", "/etc/passwd", true},
+		{"Parent", "bin/home", "../../..", true},
+		{"ThroughSymlink", "escape", "lib/..", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fs := afero.NewOsFs()
+			_ = afero.WriteFile(fs, filepath.Join(dir, "libexec", "cmd"), []byte("#!/bin/sh"), 0o755)
+			_ = fs.MkdirAll(filepath.Join(dir, "bin"), 0o755)
+			// ".." following a symbolic link refers to the parent of its target
+			if err := os.Symlink("libexec/..", filepath.Join(dir, "lib")); err != nil {
+				t.Skipf("cannot create symbolic link: %v", err)
+			}
+			if err := os.Symlink(filepath.FromSlash(tt.target), filepath.Join(dir, filepath.FromSlash(tt.link))); err != nil {
+				t.Skipf("cannot create symbolic link: %v", err)
+			}
+
+			err := CreateTarGz(fs, dir, io.Discard, "", nil)
+			var policyErr *PolicyViolationError
+			if tt.wantErr != errors.As(err, &policyErr) {
+				t.Errorf("CreateTarGz() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateTarGzBinPath(t *testing.T) {
+	// permission bits are never set on Windows
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "/cmd/command.yaml", []byte("binPath: ./bin/cmd"), 0o644)
+	_ = afero.WriteFile(fs, "/cmd/bin/cmd", []byte("#!/bin/sh"), 0o644)
+
+	var buf bytes.Buffer
+	if err := CreateTarGz(fs, "/cmd", &buf, "./bin/cmd", nil); err != nil {
+		t.Fatalf("CreateTarGz() error = %v", err)
+	}
+
+	gzipReader, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes := map[string]int64{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		modes[header.Name] = header.Mode
+	}
+	if modes["bin/cmd"] != 0o755 || modes["command.yaml"] != 0o644 {
+		t.Errorf("CreateTarGz() wrote modes %o (bin/cmd) and %o (command.yaml), want 755 and 644", modes["bin/cmd"], modes["command.yaml"])
+	}
+}
@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Format of a file containing a command.
+type Format string
+
+const (
+	FormatTar    Format = "tar"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarXz  Format = "tar.xz"
+	FormatTarZst Format = "tar.zst"
+	FormatZip    Format = "zip"
+	// FormatBinary is a bare executable instead of an archive.
+	FormatBinary Format = "binary"
+)
+
+var formats = []Format{FormatTar, FormatTarGz, FormatTarXz, FormatTarZst, FormatZip, FormatBinary}
+
+var suffixes = []struct {
+	suffix string
+	format Format
+}{
+	{".tar.gz", FormatTarGz},
+	{".tgz", FormatTarGz},
+	{".tar.xz", FormatTarXz},
+	{".txz", FormatTarXz},
+	{".tar.zst", FormatTarZst},
+	{".tzst", FormatTarZst},
+	{".tar", FormatTar},
+	{".zip", FormatZip},
+	{".exe", FormatBinary},
+}
+
+var signatures = []struct {
+	offset int
+	magic  []byte
+	format Format
+}{
+	{0, []byte{0x1f, 0x8b}, FormatTarGz},
+	{0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, FormatTarXz},
+	{0, []byte{0x28, 0xb5, 0x2f, 0xfd}, FormatTarZst},
+	{0, []byte("PK\x03\x04"), FormatZip},
+	{0, []byte("PK\x05\x06"), FormatZip}, // empty zip archive
+	{257, []byte("ustar"), FormatTar},
+	{0, []byte("\x7fELF"), FormatBinary},
+	{0, []byte{0xfe, 0xed, 0xfa, 0xce}, FormatBinary}, // Mach-O
+	{0, []byte{0xfe, 0xed, 0xfa, 0xcf}, FormatBinary},
+	{0, []byte{0xce, 0xfa, 0xed, 0xfe}, FormatBinary},
+	{0, []byte{0xcf, 0xfa, 0xed, 0xfe}, FormatBinary},
+	{0, []byte{0xca, 0xfe, 0xba, 0xbe}, FormatBinary}, // universal Mach-O
+	{0, []byte("MZ"), FormatBinary},                   // Windows executable
+	{0, []byte("#!"), FormatBinary},                   // script
+}
+
+// ParseFormat returns format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown archive format %q", name)
+}
+
+// DetectFormat returns format of the file downloaded from fileUrl. Format
+// specified explicitly in the registry (if not empty) takes precedence over
+// the URL suffix, which takes precedence over the file content.
+func DetectFormat(name string, fileUrl string, src io.ReadSeeker) (Format, error) {
+	if name != "" {
+		return ParseFormat(name)
+	}
+
+	filePath := fileUrl
+	if parsedUrl, err := url.Parse(fileUrl); err == nil {
+		filePath = parsedUrl.Path
+	}
+	filePath = strings.ToLower(filePath)
+	for _, s := range suffixes {
+		if strings.HasSuffix(filePath, s.suffix) {
+			return s.format, nil
+		}
+	}
+
+	header := make([]byte, 512)
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	n, err := io.ReadFull(src, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	header = header[:n]
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	for _, s := range signatures {
+		if len(header) >= s.offset+len(s.magic) && bytes.Equal(header[s.offset:s.offset+len(s.magic)], s.magic) {
+			return s.format, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot detect format of %s", fileUrl)
+}
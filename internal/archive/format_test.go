@@ -0,0 +1,44 @@
+package archive
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		url     string
+		content string
+		want    Format
+		wantErr bool
+	}{
+		{name: "RegistryField", format: "zip", url: "https://example.com/cmd.tar.gz", want: FormatZip},
+		{name: "UnknownRegistryField", format: "rar", url: "https://example.com/cmd.rar", wantErr: true},
+		{name: "TarGzSuffix", url: "https://example.com/cmd.tar.gz", want: FormatTarGz},
+		{name: "TgzSuffix", url: "https://example.com/CMD.TGZ?token=abc", want: FormatTarGz},
+		{name: "TarXzSuffix", url: "https://example.com/cmd.tar.xz", want: FormatTarXz},
+		{name: "TarZstSuffix", url: "https://example.com/cmd.tar.zst", want: FormatTarZst},
+		{name: "ZipSuffix", url: "https://example.com/cmd.zip", want: FormatZip},
+		{name: "ExeSuffix", url: "https://example.com/cmd.exe", want: FormatBinary},
+		{name: "GzipContent", url: "https://example.com/cmd", content: "\x1f\x8b\x08", want: FormatTarGz},
+		{name: "XzContent", url: "https://example.com/cmd", content: "\xfd7zXZ\x00", want: FormatTarXz},
+		{name: "ZstdContent", url: "https://example.com/cmd", content: "\x28\xb5\x2f\xfd", want: FormatTarZst},
+		{name: "ZipContent", url: "https://example.com/cmd", content: "PK\x03\x04", want: FormatZip},
+		{name: "ElfContent", url: "https://example.com/cmd", content: "\x7fELF\x02\x01", want: FormatBinary},
+		{name: "ScriptContent", url: "https://example.com/cmd", content: "#!/bin/sh\n", want: FormatBinary},
+		{name: "UnknownContent", url: "https://example.com/cmd", content: "hello", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat(tt.format, tt.url, bytes.NewReader([]byte(tt.content)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFormat() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package archive
+
+// Based on: https://gist.github.com/indraniel/1a91458984179ab4cf80
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/afero"
+)
+
+// extractor keeps state of a single extraction.
+type extractor struct {
+	fs        afero.Fs
+	outputDir string
+	limits    Limits
+	entries   int
+	totalSize int64
+	// links are created after all files, so their targets already exist
+	links []*tar.Header
+	// dirs get their modification times after all files are extracted
+	dirs []*tar.Header
+	// symlinks contains relative paths of all symbolic links in the archive
+	symlinks map[string]bool
+}
+
+func newExtractor(fs afero.Fs, outputDir string, limits Limits) *extractor {
+	log.Debugf("Start extracting archive to %s", outputDir)
+	return &extractor{fs: fs, outputDir: outputDir, limits: limits, symlinks: map[string]bool{}}
+}
+
+// extractTar extracts uncompressed tar archive into specified directory.
+func extractTar(tarStream io.Reader, fs afero.Fs, outputDir string, limits Limits) error {
+	tarReader := tar.NewReader(tarStream)
+	e := newExtractor(fs, outputDir, limits)
+
+	for {
+		header, err := tarReader.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := e.extractEntry(header, tarReader); err != nil {
+			return err
+		}
+	}
+
+	return e.finish()
+}
+
+// finish creates links and sets modification times of directories. It has to
+// be called after all entries are extracted.
+func (e *extractor) finish() error {
+	for _, header := range e.links {
+		if relPath, err := getRelativePath(header.Name); err == nil && header.Typeflag == tar.TypeSymlink {
+			e.symlinks[relPath] = true
+		}
+	}
+	for _, header := range e.links {
+		if err := e.extractLink(header); err != nil {
+			return err
+		}
+	}
+
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		e.setModTime(e.dirs[i])
+	}
+
+	return nil
+}
+
+func (e *extractor) extractEntry(header *tar.Header, content io.Reader) error {
+	e.entries++
+	if e.limits.MaxEntries > 0 && e.entries > e.limits.MaxEntries {
+		return &PolicyViolationError{header.Name, fmt.Sprintf("archive contains more than %d entries", e.limits.MaxEntries)}
+	}
+
+	relPath, err := getRelativePath(header.Name)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(e.outputDir, relPath)
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		log.Spamf("Creating directory: %s", path)
+		if err := e.fs.MkdirAll(path, 0o755); err != nil {
+			return err
+		}
+		e.dirs = append(e.dirs, header)
+	case tar.TypeReg:
+		if err := e.addSize(header.Name, header.Size); err != nil {
+			return err
+		}
+		log.Spamf("Creating file: %s", path)
+		if err := e.writeFile(path, os.FileMode(header.Mode), content); err != nil {
+			return err
+		}
+		e.setModTime(header)
+	case tar.TypeSymlink, tar.TypeLink:
+		e.links = append(e.links, header)
+	case tar.TypeXGlobalHeader:
+		// PAX global headers contain only metadata
+	default:
+		return fmt.Errorf(
+			"archive contains unknown type: %v in %s",
+			header.Typeflag,
+			path,
+		)
+	}
+
+	return nil
+}
+
+func (e *extractor) extractLink(header *tar.Header) error {
+	relPath, err := getRelativePath(header.Name)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(e.outputDir, relPath)
+
+	// links could be used to write outside of the output dir if they were
+	// created in directories being symbolic links
+	for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+		if e.symlinks[dir] {
+			return &PolicyViolationError{header.Name, "link is placed in a directory being a symbolic link"}
+		}
+	}
+
+	if err := e.fs.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	if header.Typeflag == tar.TypeLink {
+		// hard links are replaced with copies, since not every filesystem supports them
+		targetRelPath, err := getRelativePath(header.Linkname)
+		if err != nil {
+			return &PolicyViolationError{header.Name, fmt.Sprintf("invalid link target %q", header.Linkname)}
+		}
+		log.Spamf("Copying hard link: %s -> %s", path, targetRelPath)
+		return e.copyFile(header.Name, filepath.Join(e.outputDir, targetRelPath), path)
+	}
+
+	if filepath.IsAbs(header.Linkname) || strings.HasPrefix(header.Linkname, "/") {
+		return &PolicyViolationError{header.Name, fmt.Sprintf("symbolic link points to an absolute path %q", header.Linkname)}
+	}
+	if !isConfined(e.symlinks, filepath.Dir(relPath), header.Linkname) {
+		return &PolicyViolationError{header.Name, fmt.Sprintf("symbolic link points outside of the output directory %q", header.Linkname)}
+	}
+
+	linker, ok := e.fs.(afero.Linker)
+	if !ok {
+		return fmt.Errorf("cannot create symbolic link %s, filesystem doesn't support them", path)
+	}
+	log.Spamf("Creating symbolic link: %s -> %s", path, header.Linkname)
+	if err := linker.SymlinkIfPossible(filepath.FromSlash(header.Linkname), path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// isConfined checks whether the symbolic link target, relative to the dir,
+// stays within the output directory. Since ".." following a symbolic link
+// refers to the parent of the link's target, it is allowed only if the path
+// doesn't go through any of the symlinks.
+func isConfined(symlinks map[string]bool, dir string, target string) bool {
+	var components []string
+	if dir != "." {
+		components = strings.Split(filepath.ToSlash(dir), "/")
+	}
+	for _, component := range strings.Split(filepath.ToSlash(target), "/") {
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if len(components) == 0 {
+				return false
+			}
+			for i := range components {
+				if symlinks[filepath.Join(components[:i+1]...)] {
+					return false
+				}
+			}
+			components = components[:len(components)-1]
+		default:
+			components = append(components, component)
+		}
+	}
+	return true
+}
+
+func (e *extractor) writeFile(path string, mode os.FileMode, content io.Reader) error {
+	if err := e.fs.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	outFile, err := e.fs.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = outFile.Close() }()
+	if runtime.GOOS != "windows" {
+		if err = e.fs.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(outFile, content); err != nil {
+		return err
+	}
+
+	// Despite previous defer, close this file anyway,
+	// it will prevent hitting limit of open files.
+	return outFile.Close()
+}
+
+func (e *extractor) copyFile(entry string, src string, dst string) error {
+	info, err := e.fs.Stat(src)
+	if err != nil {
+		return &PolicyViolationError{entry, fmt.Sprintf("hard link target doesn't exist: %s", err)}
+	}
+	if !info.Mode().IsRegular() {
+		return &PolicyViolationError{entry, "hard link target is not a regular file"}
+	}
+	if err := e.addSize(entry, info.Size()); err != nil {
+		return err
+	}
+
+	srcFile, err := e.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	if err := e.writeFile(dst, info.Mode(), srcFile); err != nil {
+		return err
+	}
+	return e.fs.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// addSize checks whether extracting file of given size doesn't exceed limits.
+func (e *extractor) addSize(entry string, size int64) error {
+	if e.limits.MaxFileSize > 0 && size > e.limits.MaxFileSize {
+		return &PolicyViolationError{entry, fmt.Sprintf("file is larger than %d bytes", e.limits.MaxFileSize)}
+	}
+	e.totalSize += size
+	if e.limits.MaxTotalSize > 0 && e.totalSize > e.limits.MaxTotalSize {
+		return &PolicyViolationError{entry, fmt.Sprintf("extracted files are larger than %d bytes", e.limits.MaxTotalSize)}
+	}
+	return nil
+}
+
+// setModTime preserves modification time of the entry. Failures are ignored,
+// since times are not essential for commands to work.
+func (e *extractor) setModTime(header *tar.Header) {
+	if header.ModTime.IsZero() {
+		return
+	}
+	relPath, err := getRelativePath(header.Name)
+	if err != nil {
+		return
+	}
+	accessTime := header.AccessTime
+	if accessTime.IsZero() {
+		accessTime = time.Now()
+	}
+	if err := e.fs.Chtimes(filepath.Join(e.outputDir, relPath), accessTime, header.ModTime); err != nil {
+		log.Spamf("Cannot set modification time of %s: %s", header.Name, err)
+	}
+}
+
+// getRelativePath returns path of the archive entry relative to the output
+// directory. Absolute paths and paths leading outside the directory are rejected.
+func getRelativePath(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", &PolicyViolationError{name, "absolute paths are not allowed"}
+	}
+
+	relPath := filepath.Clean(filepath.FromSlash(slashed))
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", &PolicyViolationError{name, "path leads outside of the output directory"}
+	}
+
+	return relPath, nil
+}
@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+var modTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// createArchive returns tar.gz archive with given entries.
+func createArchive(t *testing.T, entries []testEntry) *bytes.Reader {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	_, _ = gzipWriter.Write(createTar(t, entries))
+	_ = gzipWriter.Close()
+	return bytes.NewReader(buf.Bytes())
+}
+
+// createTar returns uncompressed tar archive with given entries.
+func createTar(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: 0o755, Size: int64(len(e.content)), Linkname: e.linkname, ModTime: modTime}
+		if e.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tarWriter.Close()
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	limits := Limits{MaxTotalSize: 10, MaxFileSize: 6, MaxEntries: 3}
+
+	tests := []struct {
+		name       string
+		entries    []testEntry
+		wantEntry  string
+		wantReason string
+	}{
+		{
+			name:    "Valid",
+			entries: []testEntry{{"bin", tar.TypeDir, "", ""}, {"bin/cmd", tar.TypeReg, "abc", ""}, {"./command.yaml", tar.TypeReg, "abc", ""}},
+		},
+		{
+			name:       "ParentDirectory",
+			entries:    []testEntry{{"../../.bashrc", tar.TypeReg, "abc", ""}},
+			wantEntry:  "../../.bashrc",
+			wantReason: "outside",
+		},
+		{
+			name:       "NestedParentDirectory",
+			entries:    []testEntry{{"bin/../../cmd", tar.TypeReg, "abc", ""}},
+			wantEntry:  "bin/../../cmd",
+			wantReason: "outside",
+		},
+		{
+			name:       "AbsolutePath",
+			entries:    []testEntry{{"/etc/passwd", tar.TypeReg, "abc", ""}},
+			wantEntry:  "/etc/passwd",
+			wantReason: "absolute",
+		},
+		{
+			name:       "TooManyEntries",
+			entries:    []testEntry{{"a", tar.TypeReg, "", ""}, {"b", tar.TypeReg, "", ""}, {"c", tar.TypeReg, "", ""}, {"d", tar.TypeReg, "", ""}},
+			wantEntry:  "d",
+			wantReason: "more than 3 entries",
+		},
+		{
+			name:       "TooLargeFile",
+			entries:    []testEntry{{"cmd", tar.TypeReg, "1234567", ""}},
+			wantEntry:  "cmd",
+			wantReason: "larger than 6 bytes",
+		},
+		{
+			name:       "TooLargeTotal",
+			entries:    []testEntry{{"a", tar.TypeReg, "123456", ""}, {"b", tar.TypeReg, "123456", ""}},
+			wantEntry:  "b",
+			wantReason: "larger than 10 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			_ = fs.MkdirAll("/out", 0o755)
+
+			err := Extract(createArchive(t, tt.entries), FormatTarGz, fs, "/out", limits)
+
+			if tt.wantEntry == "" {
+				if err != nil {
+					t.Errorf("Extract() error = %v", err)
+				}
+				return
+			}
+			var policyErr *PolicyViolationError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Extract() error = %v, want PolicyViolationError", err)
+			}
+			if policyErr.Entry != tt.wantEntry || !strings.Contains(policyErr.Reason, tt.wantReason) {
+				t.Errorf("Extract() error = %v, want entry %q and reason containing %q", err, tt.wantEntry, tt.wantReason)
+			}
+			if exists, _ := afero.Exists(fs, "/etc/passwd"); exists {
+				t.Errorf("Extract() created file outside of the output directory")
+			}
+		})
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	outputDir := t.TempDir()
+	fs := afero.NewOsFs()
+
+	entries := []testEntry{
+		{"libexec/tool", tar.TypeReg, "#!/bin/sh", ""},
+		{"bin/tool", tar.TypeSymlink, "", "../libexec/tool"},
+		{"bin/copy", tar.TypeLink, "", "libexec/tool"},
+		{"share", tar.TypeDir, "", ""},
+	}
+	if err := Extract(createArchive(t, entries), FormatTarGz, fs, outputDir, DefaultLimits); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	for _, name := range []string{"bin/tool", "bin/copy"} {
+		content, err := afero.ReadFile(fs, filepath.Join(outputDir, name))
+		if err != nil || string(content) != "#!/bin/sh" {
+			t.Errorf("%s has content %q, error = %v", name, content, err)
+		}
+	}
+	for _, name := range []string{"libexec/tool", "share"} {
+		info, err := fs.Stat(filepath.Join(outputDir, name))
+		if err != nil || !info.ModTime().Equal(modTime) {
+			t.Errorf("%s should have modification time %s, got %v (error = %v)", name, modTime, info, err)
+		}
+	}
+}
+
+func TestExtractLinksOutsideOutputDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+	}{
+		{"AbsoluteSymlink", []testEntry{{"passwd", tar.TypeSymlink, "", "/etc/passwd"}}},
+		{"ParentSymlink", []testEntry{{"bin/home", tar.TypeSymlink, "", "../../.."}}},
+		{"ThroughSymlink", []testEntry{{"parent", tar.TypeSymlink, "", "a/.."}, {"a", tar.TypeSymlink, "", "."}}},
+		{"InsideSymlink", []testEntry{{"dir", tar.TypeSymlink, "", "."}, {"dir/x", tar.TypeSymlink, "", "y"}}},
+		{"ParentHardLink", []testEntry{{"passwd", tar.TypeLink, "", "../../etc/passwd"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Extract(createArchive(t, tt.entries), FormatTarGz, afero.NewOsFs(), t.TempDir(), DefaultLimits)
+			var policyErr *PolicyViolationError
+			if !errors.As(err, &policyErr) {
+				t.Errorf("Extract() error = %v, want PolicyViolationError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// zip archives created on other systems don't store unix permissions
+	creatorUnix  = 3
+	creatorMacOS = 19
+
+	maxLinkLength = 4096
+)
+
+// extractZip extracts zip archive into specified directory.
+func extractZip(src io.ReaderAt, size int64, fs afero.Fs, outputDir string, limits Limits) error {
+	zipReader, err := zip.NewReader(src, size)
+	if err != nil {
+		return err
+	}
+	e := newExtractor(fs, outputDir, limits)
+
+	for _, file := range zipReader.File {
+		// zip entries are converted to tar headers, so the same rules apply to both formats
+		header := &tar.Header{
+			Name:     file.Name,
+			Typeflag: tar.TypeReg,
+			Mode:     int64(file.Mode().Perm()),
+			Size:     int64(file.UncompressedSize64),
+			ModTime:  file.Modified,
+		}
+		if creator := file.CreatorVersion >> 8; creator != creatorUnix && creator != creatorMacOS {
+			header.Mode = 0o755
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+
+		switch mode := file.Mode(); {
+		case mode.IsDir():
+			header.Typeflag = tar.TypeDir
+		case mode&os.ModeSymlink != 0:
+			header.Typeflag = tar.TypeSymlink
+			linkname, err := io.ReadAll(io.LimitReader(content, maxLinkLength))
+			if err != nil {
+				_ = content.Close()
+				return err
+			}
+			header.Linkname = string(linkname)
+		}
+
+		err = e.extractEntry(header, content)
+		_ = content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.finish()
+}
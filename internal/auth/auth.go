@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/env"
+	"github.com/g2a-com/klio/internal/settings"
+)
+
+var nonAlphanumericRegexp = regexp.MustCompile(`[^A-Z0-9]`)
+
+// Credentials used to authenticate requests.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+	Headers  map[string]string
+}
+
+// Provider finds credentials for URLs. Credentials are looked up in the user
+// config file first, then in KLIO_REGISTRY_TOKEN_<HOST> environment variables
+// and finally in the .netrc file. The last two are used only for https URLs.
+type Provider struct {
+	registries []settings.Registry
+	netrc      []netrcEntry
+}
+
+// NewProvider returns a provider using given user settings and the .netrc file.
+func NewProvider(userSettings *settings.Settings) *Provider {
+	p := &Provider{netrc: loadNetrc()}
+	if userSettings != nil {
+		p.registries = userSettings.Registries
+	}
+	return p
+}
+
+// GetCredentials returns credentials for the URL or nil if there are none.
+func (p *Provider) GetCredentials(u *url.URL) *Credentials {
+	// the most specific entry from the user config wins
+	var registry *settings.Registry
+	for i, r := range p.registries {
+		if HasURLPrefix(u, r.URL) && (registry == nil || len(r.URL) > len(registry.URL)) {
+			registry = &p.registries[i]
+		}
+	}
+	if registry != nil {
+		return &Credentials{
+			Username: registry.Username,
+			Password: registry.Password,
+			Token:    registry.Token,
+			Headers:  registry.Headers,
+		}
+	}
+
+	// unlike entries of the user config, they don't specify a scheme, so
+	// they are never sent in plain text
+	if !strings.EqualFold(u.Scheme, "https") {
+		return nil
+	}
+
+	if token, ok := os.LookupEnv(getTokenEnvName(u.Hostname())); ok && token != "" {
+		return &Credentials{Token: token}
+	}
+
+	if entry := findNetrcEntry(p.netrc, u.Hostname()); entry != nil {
+		return &Credentials{Username: entry.login, Password: entry.password}
+	}
+
+	return nil
+}
+
+// HasURLPrefix checks whether the URL belongs to the location described by
+// the prefix: scheme and host (including port) have to be the same and path
+// has to start with the path of the prefix, on a "/" boundary. Unlike plain
+// string comparison, it doesn't match hosts such as "example.com.evil.tld"
+// for the "https://example.com" prefix.
+func HasURLPrefix(u *url.URL, prefix string) bool {
+	p, err := url.Parse(prefix)
+	if err != nil || p.Scheme == "" {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, p.Scheme) ||
+		!strings.EqualFold(u.Hostname(), p.Hostname()) ||
+		getPort(u) != getPort(p) {
+		return false
+	}
+	prefixPath := strings.TrimSuffix(p.Path, "/")
+	return prefixPath == "" || u.Path == prefixPath || strings.HasPrefix(u.Path, prefixPath+"/")
+}
+
+// getPort returns port of the URL, or the default port of its scheme.
+func getPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// Apply sets authentication headers of the request.
+func (c *Credentials) Apply(req *http.Request) {
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// NewClient returns http client which authenticates all requests (including
+// redirects) using credentials from the provider. Requests are sent using the
+// base transport, http.DefaultTransport is used if it is nil.
+func NewClient(provider *Provider, base http.RoundTripper) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport: &transport{base: base, provider: provider},
+	}
+}
+
+type transport struct {
+	base     http.RoundTripper
+	provider *Provider
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	credentials := t.provider.GetCredentials(req.URL)
+	if credentials == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTripper mustn't modify the original request
+	req = req.Clone(req.Context())
+	credentials.Apply(req)
+
+	return t.base.RoundTrip(req)
+}
+
+// RedactURL removes credentials from the URL, so it can be safely logged.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+func getTokenEnvName(host string) string {
+	return env.KLIO_REGISTRY_TOKEN_PREFIX + nonAlphanumericRegexp.ReplaceAllString(strings.ToUpper(host), "_")
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// netrcEntry describes a single machine (or default) entry of the .netrc file.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// loadNetrc reads the file specified by NETRC environment variable or the
+// .netrc file in the home directory. Errors are ignored, since the file is optional.
+func loadNetrc() []netrcEntry {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			return nil
+		}
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(currentUser.HomeDir, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseNetrc(string(data))
+}
+
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var tokens []string
+
+	// macro definitions end with an empty line and are skipped
+	inMacro := false
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" && (i == 0 || fields[i-1] != "password") {
+				inMacro = true
+				break
+			}
+			tokens = append(tokens, field)
+		}
+	}
+
+	var entry *netrcEntry
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			if i+1 < len(tokens) {
+				i++
+				entries = append(entries, netrcEntry{machine: tokens[i]})
+				entry = &entries[len(entries)-1]
+			}
+		case "default":
+			entries = append(entries, netrcEntry{})
+			entry = &entries[len(entries)-1]
+		case "login", "password", "account":
+			if entry == nil || i+1 >= len(tokens) {
+				continue
+			}
+			i++
+			switch tokens[i-1] {
+			case "login":
+				entry.login = tokens[i]
+			case "password":
+				entry.password = tokens[i]
+			}
+		}
+	}
+
+	return entries
+}
+
+// findNetrcEntry returns entry for the host. The default entry is ignored,
+// since requests (including redirects) can be sent to any host, and its
+// credentials would leak to all of them.
+func findNetrcEntry(entries []netrcEntry, host string) *netrcEntry {
+	for i, entry := range entries {
+		if entry.machine != "" && entry.machine == host {
+			return &entries[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/afero"
+)
+
+const defaultDirPermissions = 0o755
+
+var checksumRegexp = regexp.MustCompile(`^sha256-[0-9a-f]{64}$`)
+
+// Cache stores downloaded archives, addressed by their checksums. It is shared
+// by all projects of the current user.
+type Cache struct {
+	os  afero.Fs
+	dir string
+}
+
+// Entry describes a single archive stored in the cache.
+type Entry struct {
+	Checksum string    `json:"checksum"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+}
+
+// New returns a cache stored in the dir directory.
+func New(fs afero.Fs, dir string) *Cache {
+	return &Cache{os: fs, dir: dir}
+}
+
+// Dir returns directory in which archives are stored.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Open returns cached archive with the given checksum. Second value is false
+// if there is no such archive. Archives which content doesn't match the
+// checksum are removed from the cache.
+func (c *Cache) Open(checksum string) (afero.File, bool) {
+	if !checksumRegexp.MatchString(checksum) {
+		return nil, false
+	}
+
+	file, err := c.os.Open(c.path(checksum))
+	if err != nil {
+		return nil, false
+	}
+
+	actual, err := computeChecksum(file)
+	if err == nil && actual == checksum {
+		_, err = file.Seek(0, io.SeekStart)
+	} else if err == nil {
+		err = fmt.Errorf("checksum of the archive is %s", actual)
+	}
+	if err != nil {
+		log.Warnf("Removing corrupted archive %s from the cache: %s", checksum, err)
+		_ = file.Close()
+		_ = c.os.Remove(c.path(checksum))
+		return nil, false
+	}
+
+	return file, true
+}
+
+// Add stores archive read from src under the given checksum. Caller is
+// responsible for verifying that checksum matches the content.
+func (c *Cache) Add(checksum string, src io.Reader) error {
+	if !checksumRegexp.MatchString(checksum) {
+		return fmt.Errorf("invalid checksum: %s", checksum)
+	}
+	if err := c.os.MkdirAll(c.dir, defaultDirPermissions); err != nil {
+		return fmt.Errorf("unable to create directory: %s due to %s", c.dir, err)
+	}
+
+	// write to a temporary file first, so other processes never see partially
+	// written archives
+	tempFile, err := afero.TempFile(c.os, c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tempFile, src)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = c.os.Rename(tempFile.Name(), c.path(checksum))
+	}
+	if err != nil {
+		_ = c.os.Remove(tempFile.Name())
+	}
+
+	return err
+}
+
+// List returns all archives stored in the cache.
+func (c *Cache) List() ([]Entry, error) {
+	files, err := afero.ReadDir(c.os, c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if file.IsDir() || !checksumRegexp.MatchString(file.Name()) {
+			continue
+		}
+		entries = append(entries, Entry{Checksum: file.Name(), Size: file.Size(), ModTime: file.ModTime()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Checksum < entries[j].Checksum
+	})
+
+	return entries, nil
+}
+
+// Remove removes archive with the given checksum from the cache.
+func (c *Cache) Remove(checksum string) error {
+	if !checksumRegexp.MatchString(checksum) {
+		return fmt.Errorf("invalid checksum: %s", checksum)
+	}
+	if err := c.os.Remove(c.path(checksum)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Clean removes all archives from the cache.
+func (c *Cache) Clean() error {
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.Remove(entry.Checksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks whether content of the archive matches its checksum.
+func (c *Cache) Verify(checksum string) error {
+	file, err := c.os.Open(c.path(checksum))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	actual, err := computeChecksum(file)
+	if err != nil {
+		return err
+	}
+	if actual != checksum {
+		return fmt.Errorf("checksum of the archive is %s", actual)
+	}
+	return nil
+}
+
+func (c *Cache) path(checksum string) string {
+	return filepath.Join(c.dir, checksum)
+}
+
+func computeChecksum(src io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256-%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	content  = "archive"
+	checksum = "sha256-5a8a4b6b2d1a1bbd8e8e1a8b1e5d9e8c1bb8b8d9a4a0f5a1a5c4a6c1f0a4b2b7"
+)
+
+func TestCache(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c := New(fs, "/cache")
+	actual, _ := computeChecksum(strings.NewReader(content))
+
+	if _, ok := c.Open(actual); ok {
+		t.Errorf("Open() found archive in an empty cache")
+	}
+	if err := c.Add("../outside", strings.NewReader(content)); err == nil {
+		t.Errorf("Add() accepted invalid checksum")
+	}
+	if err := c.Add(actual, strings.NewReader(content)); err != nil {
+		t.Fatalf("Add() returned error: %s", err)
+	}
+
+	file, ok := c.Open(actual)
+	if !ok {
+		t.Fatalf("Open() didn't find added archive")
+	}
+	data, _ := io.ReadAll(file)
+	_ = file.Close()
+	if string(data) != content {
+		t.Errorf("Open() got content %q, want %q", data, content)
+	}
+
+	entries, err := c.List()
+	if err != nil || len(entries) != 1 || entries[0].Checksum != actual || entries[0].Size != int64(len(content)) {
+		t.Errorf("List() got %v, %v", entries, err)
+	}
+	if err := c.Verify(actual); err != nil {
+		t.Errorf("Verify() returned error: %s", err)
+	}
+}
+
+func TestCacheRemovesCorruptedArchives(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	c := New(fs, "/cache")
+
+	if err := c.Add(checksum, strings.NewReader(content)); err != nil {
+		t.Fatalf("Add() returned error: %s", err)
+	}
+	if err := c.Verify(checksum); err == nil {
+		t.Errorf("Verify() didn't detect corrupted archive")
+	}
+	if _, ok := c.Open(checksum); ok {
+		t.Errorf("Open() returned corrupted archive")
+	}
+	if exists, _ := afero.Exists(fs, "/cache/"+checksum); exists {
+		t.Errorf("Open() didn't remove corrupted archive")
+	}
+}
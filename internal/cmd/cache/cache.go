@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/g2a-com/klio/internal/cache"
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// Options for a cache list command.
+type listOptions struct {
+	JSON bool
+}
+
+// NewCommand creates a new cache command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage cache of downloaded commands",
+		Long: fmt.Sprintf(
+			"Cache (%s cache) manages archives downloaded by %s, which are shared by all projects. Cache is stored in %s.",
+			ctx.Config.CommandName,
+			ctx.Config.CommandName,
+			ctx.Paths.CacheDir,
+		),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newListCommand(ctx))
+	cmd.AddCommand(newCleanCommand(ctx))
+	cmd.AddCommand(newVerifyCommand(ctx))
+
+	return cmd
+}
+
+func newListCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &listOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cached archives",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			listCommand(ctx, opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "print archives in JSON format")
+
+	return cmd
+}
+
+func newCleanCommand(ctx context.CLIContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "clean [checksum...]",
+		Short: "Remove cached archives",
+		Long:  "Remove archives with given checksums from the cache, or all of them if no checksum is provided.",
+		Run: func(_ *cobra.Command, args []string) {
+			cleanCommand(ctx, args)
+		},
+	}
+}
+
+func newVerifyCommand(ctx context.CLIContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify",
+		Short: "Verify checksums of cached archives",
+		Long:  "Verify checksums of cached archives and remove corrupted ones.",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			verifyCommand(ctx)
+		},
+	}
+}
+
+func listCommand(ctx context.CLIContext, opts *listOptions, out io.Writer) {
+	entries, err := newCache(ctx).List()
+	if err != nil {
+		log.Fatalf("cannot list cached archives: %s", err)
+	}
+
+	if opts.JSON {
+		if entries == nil {
+			entries = []cache.Entry{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entries)
+	} else {
+		err = printTable(out, entries)
+	}
+	if err != nil {
+		log.Fatalf("cannot print cached archives: %s", err)
+	}
+}
+
+func cleanCommand(ctx context.CLIContext, checksums []string) {
+	c := newCache(ctx)
+
+	if len(checksums) == 0 {
+		if err := c.Clean(); err != nil {
+			log.Fatalf("cannot clean the cache: %s", err)
+		}
+		log.Info("Cache cleaned")
+		return
+	}
+
+	for _, checksum := range checksums {
+		if err := c.Remove(checksum); err != nil {
+			log.Fatalf("cannot remove %s from the cache: %s", checksum, err)
+		}
+		log.Infof("Removed %s from the cache", checksum)
+	}
+}
+
+func verifyCommand(ctx context.CLIContext) {
+	c := newCache(ctx)
+
+	entries, err := c.List()
+	if err != nil {
+		log.Fatalf("cannot list cached archives: %s", err)
+	}
+
+	corrupted := 0
+	for _, entry := range entries {
+		if err := c.Verify(entry.Checksum); err != nil {
+			corrupted++
+			log.Warnf("Archive %s is corrupted: %s", entry.Checksum, err)
+			if err := c.Remove(entry.Checksum); err != nil {
+				log.Fatalf("cannot remove %s from the cache: %s", entry.Checksum, err)
+			}
+		}
+	}
+
+	if corrupted > 0 {
+		log.Errorf("Removed %d corrupted archive(s) out of %d", corrupted, len(entries))
+		os.Exit(1)
+	}
+	log.Infof("All %d cached archive(s) are valid", len(entries))
+}
+
+func newCache(ctx context.CLIContext) *cache.Cache {
+	return cache.New(afero.NewOsFs(), ctx.Paths.CacheDir)
+}
+
+func printTable(out io.Writer, entries []cache.Entry) error {
+	if len(entries) == 0 {
+		log.Info("Cache is empty")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CHECKSUM\tSIZE\tMODIFIED")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", e.Checksum, e.Size, e.ModTime.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
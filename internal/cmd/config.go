@@ -18,6 +18,20 @@ type Config struct {
 	Version string `yaml:"version,omitempty"`
 }
 
+// SupportedAPIVersions lists versions of command.yaml files which can be run.
+var SupportedAPIVersions = []string{"g2a-cli/v1beta1", "g2a-cli/v1beta2", "g2a-cli/v1beta3", "g2a-cli/v1beta4", "klio/v1"}
+
+// IsSupportedAPIVersion checks whether commands with the given API version
+// can be run.
+func IsSupportedAPIVersion(apiVersion string) bool {
+	for _, v := range SupportedAPIVersions {
+		if v == apiVersion {
+			return true
+		}
+	}
+	return false
+}
+
 // LoadConfig reads a command configuration file.
 func LoadConfig(filePath string) (*Config, error) {
 	commandConfig := &Config{}
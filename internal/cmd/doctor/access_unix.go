@@ -0,0 +1,14 @@
+//go:build !windows
+
+package doctor
+
+import "syscall"
+
+// writeOK is the W_OK mode of access(2).
+const writeOK = 0x2
+
+// isWritable checks whether files can be created in the directory, without
+// creating any.
+func isWritable(dir string) bool {
+	return syscall.Access(dir, writeOK) == nil
+}
@@ -0,0 +1,11 @@
+package doctor
+
+import "os"
+
+// isWritable checks whether files can be created in the directory, without
+// creating any. Windows doesn't use permission bits, only the read-only
+// attribute is reported.
+func isWritable(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.Mode().Perm()&0o200 != 0
+}
@@ -0,0 +1,374 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/g2a-com/klio/internal/auth"
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/g2a-com/klio/internal/project"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	okStatus      = "ok"
+	warningStatus = "warning"
+	errorStatus   = "error"
+
+	projectScope = "project"
+	globalScope  = "global"
+
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+)
+
+// Options for a doctorCommand command.
+type options struct {
+	Output string
+}
+
+// check is a result of a single diagnostic.
+type check struct {
+	Name    string `json:"name" yaml:"name"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Fix describes how to solve the problem, it is empty for passed checks.
+	Fix string `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+// report is a machine-readable result of the doctor command.
+type report struct {
+	// Status is the most severe status of all checks.
+	Status string  `json:"status" yaml:"status"`
+	Checks []check `json:"checks" yaml:"checks"`
+}
+
+// NewCommand creates a new doctorCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose problems with installed commands",
+		Long: fmt.Sprintf(
+			"Doctor (%s doctor) checks the project, installed commands and registries, and suggests how to fix "+
+				"found problems. Nothing is modified. Exit status is non-zero if any check fails.",
+			ctx.Config.CommandName,
+		),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			doctorCommand(ctx, opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", tableOutput, "output format: table, json or yaml")
+
+	return cmd
+}
+
+func doctorCommand(ctx context.CLIContext, opts *options, out io.Writer) {
+	d := &doctor{ctx: ctx, mgr: manager.NewManagerForContext(ctx)}
+	d.run()
+
+	r := report{Status: okStatus, Checks: d.checks}
+	for _, c := range d.checks {
+		if c.Status == errorStatus || (c.Status == warningStatus && r.Status == okStatus) {
+			r.Status = c.Status
+		}
+	}
+
+	var err error
+	switch opts.Output {
+	case tableOutput:
+		err = printTable(out, r)
+	case jsonOutput:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(r)
+	case yamlOutput:
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		err = encoder.Encode(r)
+	default:
+		log.Fatalf("unsupported output format: %s", opts.Output)
+	}
+	if err != nil {
+		log.Fatalf("cannot print the report: %s", err)
+	}
+
+	if r.Status == errorStatus {
+		os.Exit(1)
+	}
+}
+
+// doctor collects results of checks.
+type doctor struct {
+	ctx    context.CLIContext
+	mgr    *manager.Manager
+	checks []check
+}
+
+func (d *doctor) add(c check) {
+	d.checks = append(d.checks, c)
+}
+
+func (d *doctor) run() {
+	d.checkDir("project directory", d.ctx.Paths.ProjectInstallDir)
+	d.checkDir("global directory", d.ctx.Paths.GlobalInstallDir)
+	d.checkDir("cache directory", d.ctx.Paths.CacheDir)
+
+	projectConfig := d.checkProjectConfig()
+	d.checkInstallDir(projectScope, d.ctx.Paths.ProjectInstallDir, projectConfig)
+	d.checkInstallDir(globalScope, d.ctx.Paths.GlobalInstallDir, nil)
+	d.checkRegistries(projectConfig)
+}
+
+// checkDir checks whether the directory can be used for storing files.
+// Missing directories are fine, they are created when needed.
+func (d *doctor) checkDir(name string, dir string) {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		d.add(check{Name: name, Status: okStatus, Path: dir, Message: "doesn't exist yet, it will be created when needed"})
+		return
+	case err != nil:
+		d.add(check{Name: name, Status: errorStatus, Path: dir, Message: err.Error(), Fix: fmt.Sprintf("check permissions of %s", dir)})
+		return
+	case !info.IsDir():
+		d.add(check{Name: name, Status: errorStatus, Path: dir, Message: "isn't a directory", Fix: fmt.Sprintf("remove %s", dir)})
+		return
+	}
+
+	if !isWritable(dir) {
+		d.add(check{Name: name, Status: errorStatus, Path: dir, Message: "isn't writable", Fix: fmt.Sprintf("check permissions of %s", dir)})
+		return
+	}
+	d.add(check{Name: name, Status: okStatus, Path: dir, Message: "is writable"})
+}
+
+// checkProjectConfig checks the project config and lock files. It returns
+// the project config, or nil if it doesn't exist or is invalid.
+func (d *doctor) checkProjectConfig() *project.Config {
+	cmdName := d.ctx.Config.CommandName
+	configName := d.ctx.Config.ProjectConfigFileName
+	configFile := d.ctx.Paths.ProjectConfigFile
+
+	projectConfig, err := project.LoadProjectConfig(configFile)
+	if err != nil {
+		d.add(check{Name: configName, Status: errorStatus, Path: configFile, Message: err.Error(), Fix: fmt.Sprintf("fix syntax of %s", configFile)})
+		return nil
+	}
+	if !projectConfig.Meta.Exists {
+		d.add(check{
+			Name:    configName,
+			Status:  warningStatus,
+			Message: "not found in the current directory or its parents",
+			Fix:     fmt.Sprintf(`run "%s init" to create a project, if commands are meant to be installed for it`, cmdName),
+		})
+		return nil
+	}
+	d.add(check{Name: configName, Status: okStatus, Path: configFile, Message: fmt.Sprintf("%d dependency(ies)", len(projectConfig.Dependencies))})
+
+	lockName := d.ctx.Config.ProjectLockFileName
+	lockFile := d.ctx.Paths.ProjectLockFile
+	projectLock, err := project.LoadLock(lockFile)
+	switch {
+	case err != nil:
+		d.add(check{Name: lockName, Status: errorStatus, Path: lockFile, Message: err.Error(), Fix: fmt.Sprintf(`remove %s and run "%s get"`, lockFile, cmdName)})
+	case !projectLock.Meta.Exists:
+		d.add(check{Name: lockName, Status: okStatus, Message: fmt.Sprintf(`doesn't exist yet, it is created by "%s get"`, cmdName)})
+	default:
+		if err := projectLock.Verify(projectConfig); err != nil {
+			d.add(check{Name: lockName, Status: warningStatus, Path: lockFile, Message: err.Error(), Fix: fmt.Sprintf(`run "%s get" to update it`, cmdName)})
+		} else {
+			d.add(check{Name: lockName, Status: okStatus, Path: lockFile, Message: fmt.Sprintf("in sync with %s", configName)})
+		}
+	}
+
+	return projectConfig
+}
+
+// checkInstallDir checks installed commands listed in dependencies.json of
+// the install directory. For the project, commands missing in the index are
+// reported too.
+func (d *doctor) checkInstallDir(scope string, installDir string, projectConfig *project.Config) {
+	entries, problems := d.mgr.CheckInstallDir(installDir)
+
+	entryProblems := map[string][]manager.Problem{}
+	for _, problem := range problems {
+		if problem.Entry != nil {
+			entryProblems[problem.Entry.Alias] = append(entryProblems[problem.Entry.Alias], problem)
+			continue
+		}
+		status, fix := d.describeProblem(scope, problem)
+		d.add(check{Name: fmt.Sprintf("%s directory", scope), Status: status, Path: problem.Path, Message: problem.Message, Fix: fix})
+	}
+
+	installed := map[string]bool{}
+	for _, entry := range entries {
+		installed[entry.Alias] = true
+		name := fmt.Sprintf("%s command %s", scope, entry.Alias)
+		if len(entryProblems[entry.Alias]) == 0 {
+			d.add(check{Name: name, Status: okStatus, Path: entry.Path, Message: describeEntry(entry)})
+		}
+		for _, problem := range entryProblems[entry.Alias] {
+			status, fix := d.describeProblem(scope, problem)
+			d.add(check{Name: name, Status: status, Path: problem.Path, Message: problem.Message, Fix: fix})
+		}
+	}
+
+	if projectConfig == nil {
+		return
+	}
+	for _, dep := range projectConfig.Dependencies {
+		if !installed[dep.Alias] {
+			d.add(check{
+				Name:    fmt.Sprintf("%s command %s", scope, dep.Alias),
+				Status:  errorStatus,
+				Message: fmt.Sprintf("listed in %s, but not installed", d.ctx.Config.ProjectConfigFileName),
+				Fix:     fmt.Sprintf(`run "%s get"`, d.ctx.Config.CommandName),
+			})
+		}
+	}
+}
+
+// describeProblem returns status and an actionable fix of the problem found
+// in the install directory.
+func (d *doctor) describeProblem(scope string, problem manager.Problem) (string, string) {
+	cmdName := d.ctx.Config.CommandName
+	switch problem.Kind {
+	case manager.InvalidIndexProblem:
+		if scope == projectScope {
+			return errorStatus, fmt.Sprintf(`remove %s and run "%s get"`, problem.Path, cmdName)
+		}
+		return errorStatus, fmt.Sprintf(`remove %s and install global commands again with "%s get -g"`, problem.Path, cmdName)
+	case manager.UnusedDirectoryProblem:
+		return warningStatus, fmt.Sprintf("remove %s", problem.Path)
+	case manager.StaleLockProblem:
+		return warningStatus, fmt.Sprintf("make sure no other %s process is running and remove %s", cmdName, problem.Path)
+	case manager.HeldLockProblem:
+		return warningStatus, fmt.Sprintf("wait for the other %s process to finish, or stop it if it hangs", cmdName)
+	case manager.UnsupportedAPIVersionProblem:
+		return errorStatus, fmt.Sprintf("update %s or install an older version of the command", cmdName)
+	case manager.NotExecutableProblem:
+		return errorStatus, fmt.Sprintf("run \"chmod +x %s\"", problem.Path)
+	}
+
+	// remaining problems are solved by installing the command again
+	if problem.Entry.IsLink() {
+		flag := ""
+		if scope == globalScope {
+			flag = " -g"
+		}
+		if problem.Kind == manager.MissingDirectoryProblem {
+			return errorStatus, fmt.Sprintf(`run "%s unlink%s %s"`, cmdName, flag, problem.Entry.Alias)
+		}
+		return errorStatus, fmt.Sprintf("fix the command in %s", problem.Entry.Path)
+	}
+	return errorStatus, d.reinstallFix(scope, problem.Entry)
+}
+
+// reinstallFix returns instructions for installing the command again.
+func (d *doctor) reinstallFix(scope string, entry *dependency.DependenciesIndexEntry) string {
+	if scope == projectScope {
+		return fmt.Sprintf(`run "%s get" to install it again`, d.ctx.Config.CommandName)
+	}
+	args := []string{d.ctx.Config.CommandName, "get", "-g", entry.Name, "--version", entry.Version, "--from", entry.Registry}
+	if entry.Alias != entry.Name {
+		args = append(args, "--as", entry.Alias)
+	}
+	return fmt.Sprintf(`run "%s" to install it again`, strings.Join(args, " "))
+}
+
+// checkRegistries checks whether registries used by the project and
+// installed commands are reachable.
+func (d *doctor) checkRegistries(projectConfig *project.Config) {
+	registries := map[string]bool{}
+	if d.ctx.Config.DefaultRegistry != "" {
+		registries[d.ctx.Config.DefaultRegistry] = true
+	}
+	if projectConfig != nil {
+		if projectConfig.DefaultRegistry != "" {
+			registries[projectConfig.DefaultRegistry] = true
+		}
+		for _, dep := range projectConfig.Dependencies {
+			dep.SetDefaults(projectConfig.DefaultRegistry)
+			registries[dep.Registry] = true
+		}
+	}
+	for _, entry := range d.mgr.GetInstalledCommands(d.ctx.Paths) {
+		if !entry.IsLink() && entry.Registry != "" {
+			registries[entry.Registry] = true
+		}
+	}
+
+	urls := make([]string, 0, len(registries))
+	for url := range registries {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		name := fmt.Sprintf("registry %s", auth.RedactURL(url))
+		if d.mgr.Offline {
+			d.add(check{Name: name, Status: okStatus, Message: "not checked in offline mode"})
+			continue
+		}
+		if err := d.mgr.CheckRegistry(url); err != nil {
+			d.add(check{
+				Name:    name,
+				Status:  errorStatus,
+				Message: err.Error(),
+				Fix:     fmt.Sprintf("check the URL, network connection and credentials configured in %s", d.ctx.Paths.UserConfigFile),
+			})
+			continue
+		}
+		d.add(check{Name: name, Status: okStatus, Message: "is reachable"})
+	}
+}
+
+// describeEntry returns a short description of the installed command.
+func describeEntry(entry dependency.DependenciesIndexEntry) string {
+	if entry.IsLink() {
+		return fmt.Sprintf("linked to %s", entry.Path)
+	}
+	return fmt.Sprintf("%s@%s from %s", entry.Name, entry.Version, auth.RedactURL(entry.Registry))
+}
+
+func printTable(out io.Writer, r report) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "STATUS\tCHECK\tMESSAGE")
+	for _, c := range r.Checks {
+		message := c.Message
+		if c.Path != "" && !strings.Contains(message, c.Path) {
+			message = fmt.Sprintf("%s: %s", c.Path, message)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", c.Status, c.Name, message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var fixes []string
+	for _, c := range r.Checks {
+		if c.Fix != "" {
+			fixes = append(fixes, fmt.Sprintf("  - %s: %s", c.Name, c.Fix))
+		}
+	}
+	if len(fixes) == 0 {
+		log.Info("No problems found")
+		return nil
+	}
+	_, err := fmt.Fprintf(out, "\nTo fix found problems:\n%s\n", strings.Join(fixes, "\n"))
+	return err
+}
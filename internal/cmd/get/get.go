@@ -7,6 +7,7 @@ import (
 	"github.com/g2a-com/klio/internal/context"
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/dependency/registry"
 	"github.com/g2a-com/klio/internal/log"
 	"github.com/g2a-com/klio/internal/scope"
 	"github.com/spf13/cobra"
@@ -19,8 +20,10 @@ type options struct {
 	From    string
 	As      string
 	Version string
+	Init    bool
 	NoInit  bool
 	Upgrade bool
+	Frozen  bool
 }
 
 // NewCommand creates a new getCommand command.
@@ -39,9 +42,12 @@ func NewCommand(ctx context.CLIContext) *cobra.Command {
 	cmd.Flags().BoolVar(&opts.NoSave, "no-save", false, "prevent saving to dependencies")
 	cmd.Flags().StringVar(&opts.From, "from", "", "address of the registry")
 	cmd.Flags().StringVar(&opts.As, "as", "", "changes name under which dependency is installed")
+	cmd.Flags().BoolVar(&opts.Init, "init", false, fmt.Sprintf("create %s if it doesn't exist", ctx.Config.ProjectConfigFileName))
 	cmd.Flags().BoolVar(&opts.NoInit, "no-init", false, "prevent creating config file if not exist")
+	_ = cmd.Flags().MarkDeprecated("no-init", fmt.Sprintf("%s isn't created implicitly anymore, use --init or %s init to create it", ctx.Config.ProjectConfigFileName, ctx.Config.CommandName))
 	cmd.Flags().StringVar(&opts.Version, "version", "*", "version range of the dependency")
 	cmd.Flags().BoolVar(&opts.Upgrade, "upgrade", false, fmt.Sprintf("download the latest available version instead of the one defined in %s.yaml", ctx.Config.CommandName))
+	cmd.Flags().BoolVar(&opts.Frozen, "frozen", false, fmt.Sprintf("install exactly what is locked in %s and fail if it is out of sync with %s", ctx.Config.ProjectLockFileName, ctx.Config.ProjectConfigFileName))
 
 	return cmd
 }
@@ -50,10 +56,12 @@ func getCommand(ctx context.CLIContext, opts *options, args []string) {
 	var getScope scope.Scope
 	var err error
 
-	if opts.Global {
+	if opts.Frozen {
+		getScope = getFrozenScope(ctx, opts, args)
+	} else if opts.Global {
 		getScope, err = scope.NewGlobal(&ctx)
 	} else {
-		getScope, err = scope.NewLocal(&ctx, opts.NoInit, opts.NoSave)
+		getScope, err = scope.NewLocal(&ctx, (opts.Init || scope.IsAutoInit()) && !opts.NoInit, opts.NoSave)
 	}
 	if err != nil {
 		log.Fatalf("scope initialization failed: %s", err)
@@ -94,16 +102,44 @@ func getCommand(ctx context.CLIContext, opts *options, args []string) {
 	log.Infof("All dependencies (%s) installed successfully", strings.Join(formattingArray, ","))
 }
 
+// getFrozenScope returns project scope which installs dependencies exactly as
+// they are locked, without modifying project config nor lock file.
+func getFrozenScope(ctx context.CLIContext, opts *options, args []string) scope.Scope {
+	if opts.Global || opts.Upgrade || len(args) > 0 {
+		log.Fatal("--frozen can be used only for installing project dependencies, without --global, --upgrade and arguments")
+	}
+
+	localScope, err := scope.NewLocal(&ctx, false, true)
+	if err != nil {
+		log.Fatalf("scope initialization failed: %s", err)
+	}
+	if err := localScope.VerifyLock(); err != nil {
+		log.Fatalf("%s is out of sync with %s: %s", ctx.Config.ProjectLockFileName, ctx.Config.ProjectConfigFileName, err)
+	}
+
+	return localScope
+}
+
 // getLatestVersions updates the version field of dependencies to the latest available version.
 func getLatestVersions(ctx context.CLIContext, deps []dependency.Dependency) []dependency.Dependency {
-	manager := manager.NewManager()
-	manager.DefaultRegistry = ctx.Config.DefaultRegistry
+	manager := manager.NewManagerForContext(ctx)
 
 	for i := range deps {
 		dep := &deps[i]
 
+		// Ranges have to be resolved to the highest matching version first
+		current := *dep
+		if !registry.Version(current.Version).IsExact() {
+			version, err := manager.GetMatchingVersionFor(current)
+			if err != nil || version == "" {
+				log.Debugf("Failed to resolve version of %s: %s", dep.Name, err)
+				continue
+			}
+			current.Version = version
+		}
+
 		// Get latest version using GetUpdateFor
-		updates, err := manager.GetUpdateFor(*dep)
+		updates, err := manager.GetUpdateFor(current)
 		if err != nil {
 			log.Debugf("Failed to get updates for %s: %s", dep.Name, err)
 			continue
@@ -0,0 +1,176 @@
+package init
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/g2a-com/klio/internal/project"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const gitignoreFileName = ".gitignore"
+
+// Options for an initCommand command.
+type options struct {
+	Registry    string
+	Yes         bool
+	NoGitignore bool
+}
+
+// NewCommand creates a new initCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "init [command[@version]...]",
+		Short: "Create a new project",
+		Long: fmt.Sprintf(
+			"Init (%s init) creates %s in the current directory, optionally with dependencies, and adds %s to %s. "+
+				"Default registry and dependencies are asked for when run in a terminal, unless --yes is used. "+
+				"Dependencies are added without installing them, run \"%s get\" afterwards.",
+			ctx.Config.CommandName,
+			ctx.Config.ProjectConfigFileName,
+			ctx.Config.InstallDirName,
+			gitignoreFileName,
+			ctx.Config.CommandName,
+		),
+		Run: func(cmd *cobra.Command, args []string) {
+			initCommand(ctx, opts, args, cmd.Flags().Changed("registry"), cmd.ErrOrStderr())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Registry, "registry", ctx.Config.DefaultRegistry, "default registry of the project")
+	cmd.Flags().BoolVarP(&opts.Yes, "yes", "y", false, "don't ask any questions, use flags and arguments only")
+	cmd.Flags().BoolVar(&opts.NoGitignore, "no-gitignore", false, fmt.Sprintf("don't modify %s", gitignoreFileName))
+
+	return cmd
+}
+
+func initCommand(ctx context.CLIContext, opts *options, args []string, registryChanged bool, prompt io.Writer) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("can't determine working directory: %s", err)
+	}
+	configFile := filepath.Join(workDir, ctx.Config.ProjectConfigFileName)
+	if _, err := os.Stat(configFile); err == nil {
+		log.Fatalf("%s already exists", configFile)
+	}
+
+	// ask for missing values when run by a user
+	if !opts.Yes && term.IsTerminal(int(os.Stdin.Fd())) {
+		reader := bufio.NewReader(os.Stdin)
+		if !registryChanged {
+			opts.Registry = ask(reader, prompt, "Default registry", opts.Registry)
+		}
+		if len(args) == 0 {
+			args = strings.Fields(ask(reader, prompt, "Commands to add (separated by spaces)", ""))
+		}
+	}
+
+	projectConfig := project.NewProjectConfig(opts.Registry)
+	addDependencies(ctx, projectConfig, args)
+
+	header := fmt.Sprintf(
+		"Commands used by this project, install them by running \"%s get\".\nAdd new ones with \"%s get <command>\".",
+		ctx.Config.CommandName,
+		ctx.Config.CommandName,
+	)
+	if err := project.CreateProjectConfig(projectConfig, configFile, header); err != nil {
+		log.Fatalf("cannot create project: %s", err)
+	}
+	log.Infof("Created %s", configFile)
+
+	if !opts.NoGitignore {
+		gitignoreFile := filepath.Join(workDir, gitignoreFileName)
+		added, err := addToGitignore(gitignoreFile, "/"+ctx.Config.InstallDirName+"/")
+		if err != nil {
+			log.Fatalf("cannot update %s: %s", gitignoreFile, err)
+		}
+		if added {
+			log.Infof("Added %s to %s", ctx.Config.InstallDirName, gitignoreFile)
+		}
+	}
+}
+
+// addDependencies adds dependencies specified as "name" or "name@version" to
+// the project config. Versions are resolved using the default registry, so
+// they are saved in the same way as by the get command.
+func addDependencies(ctx context.CLIContext, projectConfig *project.Config, args []string) {
+	if len(args) == 0 {
+		return
+	}
+
+	depMgr := manager.NewManagerForContext(ctx)
+	for _, arg := range args {
+		name, version, _ := strings.Cut(arg, "@")
+		if version == "" {
+			version = "*"
+		}
+		dep := dependency.Dependency{Name: name, Version: version}
+		dep.SetDefaults(projectConfig.DefaultRegistry)
+
+		resolvedVersion, err := depMgr.GetMatchingVersionFor(dep)
+		if err != nil {
+			log.Fatalf("cannot find %s in %s: %s", dep.Name, dep.Registry, err)
+		}
+		if resolvedVersion == "" {
+			log.Fatalf("cannot find %s@%s in %s", dep.Name, dep.Version, dep.Registry)
+		}
+		projectConfig.SetDependency(dep, resolvedVersion)
+	}
+}
+
+// ask prints the question and returns the answer, or defaultValue if the
+// answer is empty.
+func ask(reader *bufio.Reader, prompt io.Writer, question string, defaultValue string) string {
+	if defaultValue != "" {
+		question += fmt.Sprintf(" [%s]", defaultValue)
+	}
+	_, _ = fmt.Fprintf(prompt, "%s: ", question)
+
+	answer, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatalf("cannot read the answer: %s", err)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue
+	}
+	return answer
+}
+
+// addToGitignore appends the pattern to the .gitignore file, unless it is
+// ignored already. It returns true if the file has been modified.
+func addToGitignore(filePath string, pattern string) (bool, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	name := strings.Trim(pattern, "/")
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.Trim(strings.TrimSpace(line), "/") == name {
+			return false, nil
+		}
+	}
+
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		pattern = "\n" + pattern
+	}
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fmt.Fprintln(file, pattern); err != nil {
+		_ = file.Close()
+		return false, err
+	}
+	return true, file.Close()
+}
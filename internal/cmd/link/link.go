@@ -0,0 +1,71 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/cmd"
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/cobra"
+)
+
+const commandConfigFileName = "command.yaml"
+
+// Options for a linkCommand command.
+type options struct {
+	Global bool
+	As     string
+}
+
+// NewCommand creates a new linkCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "link <dir>",
+		Short: "Use command from a local directory",
+		Long: fmt.Sprintf(
+			"Link (%s link) registers a directory with a command under development, so it can be used without packing and installing it after each change. "+
+				"Linked command is used until it is unlinked (%s unlink) or replaced by an installed one.",
+			ctx.Config.CommandName,
+			ctx.Config.CommandName,
+		),
+		Args: cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			linkCommand(ctx, opts, args[0])
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Global, "global", "g", false, "link command globally")
+	cmd.Flags().StringVar(&opts.As, "as", "", "use a different name for the command (default: name of the executable)")
+
+	return cmd
+}
+
+func linkCommand(ctx context.CLIContext, opts *options, dir string) {
+	installDir := ctx.Paths.GlobalInstallDir
+	if !opts.Global {
+		if _, err := os.Stat(ctx.Paths.ProjectConfigFile); err != nil {
+			log.Fatalf("cannot find project config file %s, use --global to link the command globally", ctx.Paths.ProjectConfigFile)
+		}
+		installDir = ctx.Paths.ProjectInstallDir
+	}
+
+	alias := opts.As
+	if alias == "" {
+		commandConfig, err := cmd.LoadConfig(filepath.Join(dir, commandConfigFileName))
+		if err != nil {
+			log.Fatalf("cannot load command config: %s", err)
+		}
+		alias = strings.TrimSuffix(filepath.Base(commandConfig.BinPath), filepath.Ext(commandConfig.BinPath))
+	}
+
+	entry, err := manager.NewManagerForContext(ctx).LinkCommand(alias, dir, installDir)
+	if err != nil {
+		log.Fatalf("linking failed: %s", err)
+	}
+	log.Infof("Linked %s to %s", entry.Alias, entry.Path)
+}
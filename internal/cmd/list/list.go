@@ -0,0 +1,155 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	projectScope = "project"
+	globalScope  = "global"
+
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+)
+
+// Options for a listCommand command.
+type options struct {
+	Output string
+}
+
+// entry describes a single installed command.
+type entry struct {
+	Alias    string `json:"alias" yaml:"alias"`
+	Name     string `json:"name" yaml:"name"`
+	Version  string `json:"version" yaml:"version"`
+	Registry string `json:"registry" yaml:"registry"`
+	OS       string `json:"os" yaml:"os"`
+	Arch     string `json:"arch" yaml:"arch"`
+	Checksum string `json:"checksum" yaml:"checksum"`
+	Scope    string `json:"scope" yaml:"scope"`
+	// Shadowed is set for global commands hidden by project commands with the same alias.
+	Shadowed bool `json:"shadowed" yaml:"shadowed"`
+	// Linked is set for commands linked using "link", Path is their directory.
+	Linked bool   `json:"linked" yaml:"linked"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// NewCommand creates a new listCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List installed commands",
+		Long:  fmt.Sprintf("List (%s list) will show commands installed for the project and globally.", ctx.Config.CommandName),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			listCommand(ctx, opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", tableOutput, "output format: table, json or yaml")
+
+	return cmd
+}
+
+func listCommand(ctx context.CLIContext, opts *options, out io.Writer) {
+	entries := getEntries(ctx)
+
+	var err error
+	switch opts.Output {
+	case tableOutput:
+		err = printTable(out, entries)
+	case jsonOutput:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entries)
+	case yamlOutput:
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		err = encoder.Encode(entries)
+	default:
+		log.Fatalf("unsupported output format: %s", opts.Output)
+	}
+	if err != nil {
+		log.Fatalf("cannot print installed commands: %s", err)
+	}
+}
+
+func getEntries(ctx context.CLIContext) []entry {
+	entries := []entry{}
+	projectAliases := map[string]bool{}
+
+	for _, dep := range manager.NewManager().GetInstalledCommands(ctx.Paths) {
+		e := entry{
+			Alias:    dep.Alias,
+			Name:     dep.Name,
+			Version:  dep.Version,
+			Registry: dep.Registry,
+			OS:       dep.OS,
+			Arch:     dep.Arch,
+			Checksum: dep.Checksum,
+			Linked:   dep.IsLink(),
+		}
+		if e.Linked {
+			e.Path = dep.Path
+		}
+		if ctx.Paths.IsProject(dep.InstallDir) {
+			e.Scope = projectScope
+			projectAliases[dep.Alias] = true
+		} else {
+			e.Scope = globalScope
+		}
+		entries = append(entries, e)
+	}
+
+	for i := range entries {
+		if entries[i].Scope == globalScope && projectAliases[entries[i].Alias] {
+			entries[i].Shadowed = true
+		}
+	}
+
+	return entries
+}
+
+func printTable(out io.Writer, entries []entry) error {
+	if len(entries) == 0 {
+		log.Info("No commands installed")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ALIAS\tNAME\tVERSION\tSCOPE\tOS/ARCH\tREGISTRY\tCHECKSUM")
+	for _, e := range entries {
+		scope := e.Scope
+		if e.Shadowed {
+			scope += " (shadowed)"
+		}
+		registry, checksum := e.Registry, e.Checksum
+		if e.Linked {
+			scope += " (linked)"
+			registry, checksum = "linked to "+e.Path, "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Alias, e.Name, e.Version, scope, platform(e.OS, e.Arch), registry, checksum)
+	}
+	return w.Flush()
+}
+
+func platform(goos string, goarch string) string {
+	if goos == "" {
+		goos = "any"
+	}
+	if goarch == "" {
+		goarch = "any"
+	}
+	return goos + "/" + goarch
+}
@@ -0,0 +1,209 @@
+package outdated
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/g2a-com/klio/internal/project"
+	"github.com/spf13/cobra"
+)
+
+const (
+	projectScope = "project"
+	globalScope  = "global"
+)
+
+// Options for an outdatedCommand command.
+type options struct {
+	JSON     bool
+	ExitCode bool
+}
+
+// report describes available updates of a single dependency.
+type report struct {
+	Alias       string `json:"alias"`
+	Name        string `json:"name"`
+	Registry    string `json:"registry"`
+	Scope       string `json:"scope"`
+	Current     string `json:"current"`
+	Wanted      string `json:"wanted"`
+	NonBreaking string `json:"latestNonBreaking"`
+	Breaking    string `json:"latestBreaking"`
+	Error       string `json:"error,omitempty"`
+
+	constraint string
+}
+
+// NewCommand creates a new outdatedCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "Check installed commands for updates",
+		Long:  fmt.Sprintf("Outdated (%s outdated) will check registries for newer versions of project and global commands.", ctx.Config.CommandName),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			outdatedCommand(ctx, opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "print report in JSON format")
+	cmd.Flags().BoolVar(&opts.ExitCode, "exit-code", false, "exit with code 1 if any command is outdated")
+
+	return cmd
+}
+
+func outdatedCommand(ctx context.CLIContext, opts *options, out io.Writer) {
+	reports := getReports(ctx)
+	checkForUpdates(ctx, reports)
+
+	var err error
+	if opts.JSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(reports)
+	} else {
+		err = printTable(out, reports)
+	}
+	if err != nil {
+		log.Fatalf("cannot print report: %s", err)
+	}
+
+	if opts.ExitCode {
+		for _, r := range reports {
+			if r.isOutdated() {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// getReports lists dependencies declared in the project config and installed
+// in both scopes.
+func getReports(ctx context.CLIContext) []*report {
+	var reports []*report
+	projectReports := map[string]*report{}
+
+	projectConfig, err := project.LoadProjectConfig(ctx.Paths.ProjectConfigFile)
+	if err != nil {
+		log.Warnf("cannot load project config: %s", err)
+	} else {
+		for _, dep := range projectConfig.Dependencies {
+			r := &report{
+				Alias:      dep.Alias,
+				Name:       dep.Name,
+				Registry:   dep.Registry,
+				Scope:      projectScope,
+				constraint: dep.Version,
+			}
+			projectReports[dep.Alias] = r
+			reports = append(reports, r)
+		}
+	}
+
+	for _, entry := range manager.NewManager().GetInstalledCommands(ctx.Paths) {
+		if entry.IsLink() {
+			continue
+		}
+		scope := globalScope
+		if ctx.Paths.IsProject(entry.InstallDir) {
+			scope = projectScope
+			if r, ok := projectReports[entry.Alias]; ok && r.Name == entry.Name && r.Registry == entry.Registry {
+				r.Current = entry.Version
+				continue
+			}
+		}
+		reports = append(reports, &report{
+			Alias:    entry.Alias,
+			Name:     entry.Name,
+			Registry: entry.Registry,
+			Scope:    scope,
+			Current:  entry.Version,
+		})
+	}
+
+	return reports
+}
+
+// checkForUpdates fills reports with versions available in registries. Checks
+// are done in parallel.
+func checkForUpdates(ctx context.CLIContext, reports []*report) {
+	depMgr := manager.NewManagerForContext(ctx)
+
+	var wg sync.WaitGroup
+	for _, r := range reports {
+		wg.Add(1)
+		go func(r *report) {
+			defer wg.Done()
+			if err := r.check(depMgr); err != nil {
+				log.Debugf("Failed to check updates for %s: %s", r.Alias, err)
+				r.Error = err.Error()
+			}
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (r *report) check(depMgr *manager.Manager) error {
+	if r.constraint != "" {
+		wanted, err := depMgr.GetMatchingVersionFor(dependency.Dependency{Registry: r.Registry, Name: r.Name, Version: r.constraint})
+		if err != nil {
+			return err
+		}
+		r.Wanted = wanted
+	}
+
+	current := r.Current
+	if current == "" {
+		current = r.Wanted
+	}
+	if current == "" {
+		return nil
+	}
+
+	updates, err := depMgr.GetUpdateFor(dependency.Dependency{Registry: r.Registry, Name: r.Name, Version: current})
+	if err != nil {
+		return err
+	}
+	r.NonBreaking = updates.NonBreaking
+	r.Breaking = updates.Breaking
+
+	return nil
+}
+
+func (r *report) isOutdated() bool {
+	return (r.Wanted != "" && r.Wanted != r.Current) || r.NonBreaking != "" || r.Breaking != ""
+}
+
+func printTable(out io.Writer, reports []*report) error {
+	if len(reports) == 0 {
+		log.Info("No commands installed")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ALIAS\tSCOPE\tCURRENT\tWANTED\tLATEST NON-BREAKING\tLATEST BREAKING")
+	for _, r := range reports {
+		if r.Error != "" {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\terror: %s\n", r.Alias, r.Scope, orDash(r.Current), r.Error)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Alias, r.Scope, orDash(r.Current), orDash(r.Wanted), orDash(r.NonBreaking), orDash(r.Breaking))
+	}
+	return w.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,199 @@
+package pack
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/archive"
+	"github.com/g2a-com/klio/internal/cmd"
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency/registry"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const commandConfigFileName = "command.yaml"
+
+// Options for a pack command.
+type options struct {
+	Name      string
+	Platform  string
+	OutputDir string
+	BaseURL   string
+}
+
+// NewCommand creates a new pack command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "pack <dir>...",
+		Short: "Build archives of commands",
+		Long: fmt.Sprintf(
+			"Pack (%s pack) validates command directories and packs each of them into a reproducible "+
+				"\"<name>-<version>-<os>-<arch>.tar.gz\" archive. Version is taken from command.yaml, OS and "+
+				"architecture are detected using the executable (scripts are packed as platform independent "+
+				"commands) unless the --platform flag is used. Checksums and registry entries of created "+
+				"archives are printed to the standard output.",
+			ctx.Config.CommandName,
+		),
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			packCommand(opts, args, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Name, "name", "", "name of the command (default: name of the executable)")
+	cmd.Flags().StringVar(&opts.Platform, "platform", "", `OS and architecture of the command, e.g. "linux/amd64"`)
+	cmd.Flags().StringVarP(&opts.OutputDir, "output-dir", "o", "dist", "directory for created archives")
+	cmd.Flags().StringVar(&opts.BaseURL, "base-url", "", "URL under which archives will be published, used by printed registry entries")
+
+	return cmd
+}
+
+func packCommand(opts *options, dirs []string, out io.Writer) {
+	if opts.Platform != "" && len(dirs) > 1 {
+		log.Fatal("--platform can be used only with a single directory")
+	}
+
+	fs := afero.NewOsFs()
+	if err := fs.MkdirAll(opts.OutputDir, 0o755); err != nil {
+		log.Fatalf("cannot create directory %s: %s", opts.OutputDir, err)
+	}
+
+	var entries []registry.Entry
+	for _, dir := range dirs {
+		entry, err := pack(fs, opts, dir)
+		if err != nil {
+			log.Fatalf("cannot pack %s: %s", dir, err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(entries); err != nil {
+		log.Fatalf("cannot print registry entries: %s", err)
+	}
+}
+
+// pack validates the command directory and creates its archive in the
+// output directory.
+func pack(fs afero.Fs, opts *options, dir string) (*registry.Entry, error) {
+	commandConfig, err := loadCommandConfig(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(commandConfig.BinPath), filepath.Ext(commandConfig.BinPath))
+	}
+	version := strings.TrimPrefix(commandConfig.Version, "v")
+
+	var goos, goarch string
+	if opts.Platform != "" {
+		var ok bool
+		if goos, goarch, ok = strings.Cut(opts.Platform, "/"); !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf(`invalid platform "%s", expected "<os>/<arch>"`, opts.Platform)
+		}
+	} else {
+		goos, goarch = registry.DetectPlatform(fs, filepath.Join(dir, commandConfig.BinPath))
+	}
+
+	fileName := strings.Join([]string{name, version, goos, goarch}, "-")
+	fileName = strings.TrimRight(fileName, "-") + ".tar.gz"
+	filePath := filepath.Join(opts.OutputDir, fileName)
+	checksum, err := writeArchive(fs, dir, filePath, commandConfig.BinPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Packed %s into %s (%s)", dir, filePath, checksum)
+
+	fileURL := fileName
+	if opts.BaseURL != "" {
+		if fileURL, err = url.JoinPath(opts.BaseURL, fileName); err != nil {
+			return nil, err
+		}
+	}
+	entry := &registry.Entry{
+		Name:     name,
+		Version:  version,
+		OS:       goos,
+		Arch:     goarch,
+		URL:      fileURL,
+		Checksum: checksum,
+	}
+	if commandConfig.Description != "" {
+		entry.Annotations = map[string]string{"description": commandConfig.Description}
+	}
+
+	return entry, nil
+}
+
+// loadCommandConfig reads and validates command.yaml of the command
+// directory.
+func loadCommandConfig(fs afero.Fs, dir string) (*cmd.Config, error) {
+	commandConfig, err := cmd.LoadConfig(filepath.Join(dir, commandConfigFileName))
+	if err != nil {
+		return nil, err
+	}
+	if !commandConfig.Meta.Exists {
+		return nil, fmt.Errorf("%s doesn't exist", commandConfigFileName)
+	}
+	if commandConfig.Version == "" {
+		return nil, fmt.Errorf("%s doesn't specify version", commandConfigFileName)
+	}
+	if !registry.Version(strings.TrimPrefix(commandConfig.Version, "v")).IsExact() {
+		return nil, fmt.Errorf(`%s specifies invalid version "%s"`, commandConfigFileName, commandConfig.Version)
+	}
+
+	binPath := filepath.Join(dir, commandConfig.BinPath)
+	if relPath, err := filepath.Rel(dir, binPath); err != nil || strings.HasPrefix(relPath, "..") {
+		return nil, fmt.Errorf("binPath %s points outside of the command directory", commandConfig.BinPath)
+	}
+	info, err := fs.Stat(binPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find binPath %s: %s", commandConfig.BinPath, err)
+	}
+	// Windows doesn't use permission bits to mark executables
+	if runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+		return nil, fmt.Errorf("binPath %s isn't executable", commandConfig.BinPath)
+	}
+
+	return commandConfig, nil
+}
+
+// writeArchive packs the directory into the file and returns its checksum.
+func writeArchive(fs afero.Fs, dir string, filePath string, binPath string) (string, error) {
+	absFilePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := fs.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	err = archive.CreateTarGz(fs, dir, io.MultiWriter(file, hash), binPath, func(relPath string, info os.FileInfo) bool {
+		// output directory may be placed inside the command directory
+		absPath, err := filepath.Abs(filepath.Join(dir, relPath))
+		return err == nil && (absPath == absFilePath || absPath == filepath.Dir(absFilePath))
+	})
+	if err != nil {
+		_ = fs.Remove(filePath)
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256-%x", hash.Sum(nil)), file.Close()
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/g2a-com/klio/internal/config"
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency/registry"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const defaultIndexFileName = "registry.yaml"
+
+// Options for a registry build command.
+type buildOptions struct {
+	BaseURL string
+	Output  string
+	Name    string
+}
+
+// NewCommand creates a new registry command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Manage registries of commands",
+		Long: fmt.Sprintf(
+			"Registry (%s registry) helps authors of commands to maintain registries they publish.",
+			ctx.Config.CommandName,
+		),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newBuildCommand(ctx))
+
+	return cmd
+}
+
+func newBuildCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &buildOptions{}
+	cmd := &cobra.Command{
+		Use:   "build <dir>",
+		Short: "Generate registry index for archives in a directory",
+		Long: fmt.Sprintf(
+			"Build (%s registry build) scans archives in the directory and adds them to the registry index. "+
+				"Each archive has to contain command.yaml. Name, version, OS and architecture are taken from file names "+
+				`(e.g. "hello-1.2.0-linux-amd64.tar.gz"), name may be specified with --name, version may be specified in command.yaml as well, and OS and `+
+				"architecture are detected using the executable if file names don't include them. "+
+				"Existing entries and annotations of the index are preserved.",
+			ctx.Config.CommandName,
+		),
+		Args: cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			buildCommand(opts, args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.BaseURL, "base-url", "", "URL under which archives are published")
+	cmd.Flags().StringVar(&opts.Name, "name", "", "name of the command (default: taken from file names)")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "path of the registry index (default \"<dir>/registry.yaml\")")
+	_ = cmd.MarkFlagRequired("base-url")
+
+	return cmd
+}
+
+func buildCommand(opts *buildOptions, dir string) {
+	output := opts.Output
+	if output == "" {
+		output = filepath.Join(dir, defaultIndexFileName)
+	}
+
+	index := &registry.Index{}
+	if err := config.LoadConfigFile(index, &index.Meta, output); err != nil {
+		log.Fatalf("cannot load registry index: %s", err)
+	}
+	entriesCount := len(index.Entries)
+
+	if err := registry.Build(afero.NewOsFs(), index, dir, opts.BaseURL, opts.Name); err != nil {
+		log.Fatalf("cannot build registry index: %s", err)
+	}
+
+	if err := config.SaveConfigFile(index, output); err != nil {
+		log.Fatalf("cannot save registry index: %s", err)
+	}
+	log.Infof("Saved %s with %d entries (%d new)", output, len(index.Entries), len(index.Entries)-entriesCount)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/g2a-com/klio/internal/context"
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/dependency/registry"
 	"github.com/g2a-com/klio/internal/env"
 	"github.com/g2a-com/klio/internal/log"
 	"github.com/g2a-com/klio/internal/project"
@@ -47,6 +48,7 @@ func loadExternalCommand(ctx context.CLIContext, rootCmd *cobra.Command, dep dep
 		dep = *updatedDep
 	}
 
+	supportedAPIVersion := cmd.IsSupportedAPIVersion(cmdConfig.APIVersion)
 	newCmd := &cobra.Command{
 		Use:                dep.Alias,
 		Short:              cmdConfig.Description,
@@ -64,8 +66,8 @@ func loadExternalCommand(ctx context.CLIContext, rootCmd *cobra.Command, dep dep
 
 			var wg sync.WaitGroup
 
-			switch cmdConfig.APIVersion {
-			case "g2a-cli/v1beta1", "g2a-cli/v1beta2", "g2a-cli/v1beta3", "g2a-cli/v1beta4", "klio/v1":
+			switch {
+			case supportedAPIVersion:
 				externalCmd.Stdout = os.Stdout
 				externalCmd.Stderr = os.Stderr
 			default:
@@ -90,6 +92,11 @@ func loadExternalCommand(ctx context.CLIContext, rootCmd *cobra.Command, dep dep
 				}
 			}
 
+			// linked commands are under development, there is nothing to update
+			if manager.IsOffline() || dep.IsLink() {
+				skipUpdates = true
+			}
+
 			if !skipUpdates {
 				go getUpdateMessage(ctx, dep, updateMsgChannel)
 				go func() {
@@ -189,12 +196,12 @@ func loadExternalCommand(ctx context.CLIContext, rootCmd *cobra.Command, dep dep
 }
 
 func getUpdateMessage(ctx context.CLIContext, dep dependency.DependenciesIndexEntry, msg chan<- string) {
-	depMgr := manager.NewManager()
+	depMgr := manager.NewManagerForContext(ctx)
 
 	getInstallCmd := func(ver string) string {
 		installMsg := fmt.Sprintf("%s get", ctx.Config.CommandName)
 
-		if ctx.Paths.IsGlobal(dep.Path) {
+		if ctx.Paths.IsGlobal(dep.InstallDir) {
 			installMsg += " -g"
 		}
 		installMsg += fmt.Sprintf(" %s --version %s --from %s", dep.Name, ver, dep.Registry)
@@ -223,7 +230,7 @@ func getUpdateMessage(ctx context.CLIContext, dep dependency.DependenciesIndexEn
 
 func autoDownloadCommand(ctx *context.CLIContext, dep dependency.DependenciesIndexEntry) (*dependency.DependenciesIndexEntry, error) {
 	args := os.Args[1:]
-	if len(args) == 0 {
+	if len(args) == 0 || dep.IsLink() {
 		return &dep, nil
 	} else {
 		command := args[0]
@@ -242,20 +249,25 @@ func autoDownloadCommand(ctx *context.CLIContext, dep dependency.DependenciesInd
 	}
 
 	updatedDep := dep
-	if isProjectScope := ctx.Paths.IsProject(dep.Path); isProjectScope && !skipAutoDownload {
+	if isProjectScope := ctx.Paths.IsProject(dep.InstallDir); isProjectScope && !skipAutoDownload {
 		projectConfig, err := project.LoadProjectConfig(ctx.Paths.ProjectConfigFile)
 		if err != nil {
 			return nil, fmt.Errorf("cannot load project config: %s", err)
 		}
+		projectLock, err := project.LoadLock(ctx.Paths.ProjectLockFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load project lock: %s", err)
+		}
 		projectDependency := projectConfig.GetDependency(dep.Alias)
+		lockedDependency := projectLock.GetDependency(dep.Alias)
 
-		if projectDependency != nil && projectDependency.Version != "" && projectDependency.Version != dep.Version {
+		if projectDependency != nil && projectDependency.Version != "" && !isInstalledAsDeclared(dep, *projectDependency, lockedDependency) {
 			localScope, err := scope.NewLocal(ctx, false, false)
 			if err != nil {
 				return nil, fmt.Errorf("cannot initialize local scope: %s", err)
 			}
 
-			log.Infof(`Installed %[1]s@%[2]s but project config defines %[1]s@%[3]s, downloading %[1]s@%[3]s now...`, dep.Alias, dep.Version, projectDependency.Version)
+			log.Infof(`Installed %[1]s@%[2]s but project defines %[1]s@%[3]s, downloading %[1]s@%[3]s now...`, dep.Alias, dep.Version, projectDependency.Version)
 
 			_, installedDep, err := localScope.InstallDependencies([]dependency.Dependency{
 				{
@@ -275,3 +287,15 @@ func autoDownloadCommand(ctx *context.CLIContext, dep dependency.DependenciesInd
 	}
 	return &updatedDep, nil
 }
+
+// isInstalledAsDeclared checks whether installed command satisfies version
+// declared in the project config and is the one recorded in the project lock.
+func isInstalledAsDeclared(dep dependency.DependenciesIndexEntry, projectDependency dependency.Dependency, lockedDependency *dependency.DependenciesIndexEntry) bool {
+	if !registry.Version(dep.Version).Match(projectDependency.Version) {
+		return false
+	}
+	if lockedDependency != nil && lockedDependency.Version != dep.Version && registry.Version(lockedDependency.Version).Match(projectDependency.Version) {
+		return false
+	}
+	return true
+}
@@ -4,8 +4,19 @@ import (
 	"os"
 	"strings"
 
+	cacheCommand "github.com/g2a-com/klio/internal/cmd/cache"
+	doctorCommand "github.com/g2a-com/klio/internal/cmd/doctor"
 	getCommand "github.com/g2a-com/klio/internal/cmd/get"
+	initCommand "github.com/g2a-com/klio/internal/cmd/init"
+	linkCommand "github.com/g2a-com/klio/internal/cmd/link"
+	listCommand "github.com/g2a-com/klio/internal/cmd/list"
+	outdatedCommand "github.com/g2a-com/klio/internal/cmd/outdated"
+	packCommand "github.com/g2a-com/klio/internal/cmd/pack"
+	registryCommand "github.com/g2a-com/klio/internal/cmd/registry"
 	removeCommand "github.com/g2a-com/klio/internal/cmd/remove"
+	searchCommand "github.com/g2a-com/klio/internal/cmd/search"
+	unlinkCommand "github.com/g2a-com/klio/internal/cmd/unlink"
+	updateCommand "github.com/g2a-com/klio/internal/cmd/update"
 	"github.com/g2a-com/klio/internal/context"
 	"github.com/g2a-com/klio/internal/dependency/manager"
 	"github.com/g2a-com/klio/internal/env"
@@ -24,6 +35,7 @@ func NewCommand(ctx context.CLIContext) *cobra.Command {
 	// Setup flag
 	verbosity := rootCommand.PersistentFlags().CountP("verbose", "v", "more verbose output (-vv... to further increase verbosity)")
 	logLevel := rootCommand.PersistentFlags().String("log-level", log.GetDefaultLevel(), "set logs level: "+strings.Join(log.LevelNames, ", "))
+	offline := rootCommand.PersistentFlags().Bool("offline", false, "use only cached registries and commands, don't connect to the network")
 
 	// Normally flags are parsed by cobra on Execute(), but we need to determine
 	// logging level before executing command, so Parse() needs to be called here
@@ -42,12 +54,28 @@ func NewCommand(ctx context.CLIContext) *cobra.Command {
 		_ = os.Setenv(env.KLIO_LOG_LEVEL, log.GetLevel())
 	}
 
+	// Offline mode is passed to installed subcommands with env variable as well.
+	if *offline {
+		_ = os.Setenv(env.KLIO_OFFLINE, "true")
+	}
+
 	// Discover commands
 	commands := manager.NewManager().GetInstalledCommands(ctx.Paths)
 
 	// Register builtin commands
+	rootCommand.AddCommand(initCommand.NewCommand(ctx))
 	rootCommand.AddCommand(getCommand.NewCommand(ctx))
 	rootCommand.AddCommand(removeCommand.NewCommand(ctx))
+	rootCommand.AddCommand(listCommand.NewCommand(ctx))
+	rootCommand.AddCommand(outdatedCommand.NewCommand(ctx))
+	rootCommand.AddCommand(updateCommand.NewCommand(ctx))
+	rootCommand.AddCommand(cacheCommand.NewCommand(ctx))
+	rootCommand.AddCommand(searchCommand.NewCommand(ctx))
+	rootCommand.AddCommand(registryCommand.NewCommand(ctx))
+	rootCommand.AddCommand(packCommand.NewCommand(ctx))
+	rootCommand.AddCommand(linkCommand.NewCommand(ctx))
+	rootCommand.AddCommand(unlinkCommand.NewCommand(ctx))
+	rootCommand.AddCommand(doctorCommand.NewCommand(ctx))
 
 	// Register external commands
 	for _, dep := range commands {
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/dependency/registry"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/cobra"
+)
+
+const descriptionAnnotation = "description"
+
+// Options for a searchCommand command.
+type options struct {
+	From string
+	JSON bool
+}
+
+// result describes a single command found in the registry.
+type result struct {
+	Name string `json:"name"`
+	// Version is the latest version compatible with the current platform.
+	Version     string            `json:"version"`
+	Description string            `json:"description"`
+	Platforms   []string          `json:"platforms"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NewCommand creates a new searchCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "search [term]",
+		Short: "Search for commands in a registry",
+		Long: fmt.Sprintf(
+			"Search (%s search) will list commands from a registry with names, descriptions or annotations containing the term. All commands are listed if the term is omitted.",
+			ctx.Config.CommandName,
+		),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			term := ""
+			if len(args) > 0 {
+				term = args[0]
+			}
+			searchCommand(ctx, opts, term, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.From, "from", "", "address of the registry")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "print results in JSON format")
+
+	return cmd
+}
+
+func searchCommand(ctx context.CLIContext, opts *options, term string, out io.Writer) {
+	entries, err := manager.NewManagerForContext(ctx).GetRegistryEntries(opts.From)
+	if err != nil {
+		log.Fatalf("cannot load registry: %s", err)
+	}
+
+	results := search(entries, term)
+
+	if opts.JSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(results)
+	} else {
+		err = printTable(out, results)
+	}
+	if err != nil {
+		log.Fatalf("cannot print results: %s", err)
+	}
+}
+
+// search returns commands matching the term (case-insensitively), sorted by
+// their names.
+func search(entries []registry.Entry, term string) []*result {
+	term = strings.ToLower(term)
+	resultsByName := map[string]*result{}
+	results := []*result{}
+
+	for _, entry := range entries {
+		if !matches(entry, term) {
+			continue
+		}
+		r, ok := resultsByName[entry.Name]
+		if !ok {
+			r = &result{Name: entry.Name}
+			resultsByName[entry.Name] = r
+			results = append(results, r)
+		}
+		r.Platforms = appendUnique(r.Platforms, platform(entry.OS, entry.Arch))
+
+		// details are taken from the latest compatible version
+		if registry.IsCompatible(entry) && (r.Version == "" || registry.Version(entry.Version).GreaterThan(registry.Version(r.Version))) {
+			r.Version = entry.Version
+			r.Description = entry.Annotations[descriptionAnnotation]
+			r.Annotations = entry.Annotations
+		}
+	}
+
+	for _, r := range results {
+		sort.Strings(r.Platforms)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	return results
+}
+
+// matches checks whether name, description or any annotation of the entry
+// contains the lower-cased term.
+func matches(entry registry.Entry, term string) bool {
+	if strings.Contains(strings.ToLower(entry.Name), term) {
+		return true
+	}
+	for key, value := range entry.Annotations {
+		if strings.Contains(strings.ToLower(key), term) || strings.Contains(strings.ToLower(value), term) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func printTable(out io.Writer, results []*result) error {
+	if len(results) == 0 {
+		log.Info("No commands found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tVERSION\tPLATFORMS\tDESCRIPTION")
+	for _, r := range results {
+		version := r.Version
+		if version == "" {
+			version = "- (not available for this platform)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, version, strings.Join(r.Platforms, ", "), r.Description)
+	}
+	return w.Flush()
+}
+
+func platform(goos string, goarch string) string {
+	if goos == "" {
+		goos = "any"
+	}
+	if goarch == "" {
+		goarch = "any"
+	}
+	return goos + "/" + goarch
+}
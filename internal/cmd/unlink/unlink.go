@@ -0,0 +1,45 @@
+package unlink
+
+import (
+	"fmt"
+
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/cobra"
+)
+
+// Options for an unlinkCommand command.
+type options struct {
+	Global bool
+}
+
+// NewCommand creates a new unlinkCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "unlink <command name>",
+		Short: "Stop using command from a local directory",
+		Long:  fmt.Sprintf("Unlink (%s unlink) removes command linked with %s link. Linked directory is left intact.", ctx.Config.CommandName, ctx.Config.CommandName),
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			unlinkCommand(ctx, opts, args[0])
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Global, "global", "g", false, "unlink command linked globally")
+
+	return cmd
+}
+
+func unlinkCommand(ctx context.CLIContext, opts *options, alias string) {
+	installDir := ctx.Paths.ProjectInstallDir
+	if opts.Global {
+		installDir = ctx.Paths.GlobalInstallDir
+	}
+
+	if err := manager.NewManagerForContext(ctx).UnlinkCommand(alias, installDir); err != nil {
+		log.Fatalf("unlinking failed: %s", err)
+	}
+	log.Infof("Unlinked %s", alias)
+}
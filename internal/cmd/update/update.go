@@ -0,0 +1,169 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/g2a-com/klio/internal/scope"
+	"github.com/spf13/cobra"
+)
+
+// Options for an updateCommand command.
+type options struct {
+	Global bool
+	Major  bool
+	DryRun bool
+}
+
+// NewCommand creates a new updateCommand command.
+func NewCommand(ctx context.CLIContext) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:   "update [alias...]",
+		Short: "Update installed commands",
+		Long: fmt.Sprintf(
+			"Update (%s update) will upgrade commands to the highest non-breaking version and save them in %s.",
+			ctx.Config.CommandName,
+			ctx.Config.ProjectConfigFileName,
+		),
+		Run: func(_ *cobra.Command, args []string) {
+			updateCommand(ctx, opts, args)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Global, "global", "g", false, "update commands installed globally")
+	cmd.Flags().BoolVar(&opts.Major, "major", false, "allow updates introducing breaking changes")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only show which commands would be updated")
+
+	return cmd
+}
+
+func updateCommand(ctx context.CLIContext, opts *options, args []string) {
+	var updateScope scope.Scope
+	var err error
+
+	if opts.Global {
+		updateScope, err = scope.NewGlobal(&ctx)
+	} else {
+		updateScope, err = scope.NewLocal(&ctx, false, false)
+	}
+	if err != nil {
+		log.Fatalf("scope initialization failed: %s", err)
+	}
+
+	dependencies, err := filterDependencies(updateScope.GetImplicitDependencies(), args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	depMgr := manager.NewManagerForContext(ctx)
+	installed := getInstalledVersions(ctx, opts.Global)
+
+	var toUpdate []dependency.Dependency
+	for _, dep := range dependencies {
+		dep.SetDefaults(depMgr.DefaultRegistry)
+		current, target, err := getTargetVersion(depMgr, dep, installed[dep.Alias], opts.Major)
+		if err != nil {
+			log.Warnf("Cannot check updates for %s: %s", dep.Alias, err)
+			continue
+		}
+		if target == "" {
+			log.Verbosef("%s@%s is up to date", dep.Alias, current)
+			continue
+		}
+		log.Infof("%s: %s -> %s", dep.Alias, current, target)
+		dep.Version = target
+		toUpdate = append(toUpdate, dep)
+	}
+
+	if len(toUpdate) == 0 {
+		log.Info("All dependencies are up to date")
+		return
+	}
+	if opts.DryRun {
+		return
+	}
+
+	updatedDeps, _, err := updateScope.InstallDependencies(toUpdate)
+	if err != nil {
+		log.Fatalf("updating dependencies failed: %s", err)
+	}
+
+	var formattingArray []string
+	for _, d := range updatedDeps {
+		formattingArray = append(formattingArray, fmt.Sprintf("%s:%s", d.Alias, d.Version))
+	}
+	log.Infof("All dependencies (%s) updated successfully", strings.Join(formattingArray, ","))
+}
+
+// filterDependencies returns dependencies with given aliases, or all of them if no alias is provided.
+func filterDependencies(deps []dependency.Dependency, aliases []string) ([]dependency.Dependency, error) {
+	if len(aliases) == 0 {
+		return deps, nil
+	}
+
+	var result []dependency.Dependency
+	for _, alias := range aliases {
+		found := false
+		for _, dep := range deps {
+			if dep.Alias == alias {
+				result = append(result, dep)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s is not a dependency", alias)
+		}
+	}
+	return result, nil
+}
+
+// getInstalledVersions returns versions of commands installed in the selected scope, by alias.
+func getInstalledVersions(ctx context.CLIContext, global bool) map[string]string {
+	versions := map[string]string{}
+	for _, entry := range manager.NewManager().GetInstalledCommands(ctx.Paths) {
+		if ctx.Paths.IsGlobal(entry.InstallDir) == global && !entry.IsLink() {
+			versions[entry.Alias] = entry.Version
+		}
+	}
+	return versions
+}
+
+// getTargetVersion returns the current version of the dependency and a version
+// it should be updated to. Target version is empty if there is no update.
+func getTargetVersion(depMgr *manager.Manager, dep dependency.Dependency, installedVersion string, allowMajor bool) (string, string, error) {
+	current := installedVersion
+	if current == "" {
+		var err error
+		if current, err = depMgr.GetMatchingVersionFor(dep); err != nil {
+			return "", "", err
+		}
+		if current == "" {
+			return "", "", fmt.Errorf("cannot find %s@%s in %s", dep.Name, dep.Version, dep.Registry)
+		}
+	}
+
+	target := ""
+	version := current
+	for {
+		updates, err := depMgr.GetUpdateFor(dependency.Dependency{Name: dep.Name, Registry: dep.Registry, Version: version})
+		if err != nil {
+			return "", "", err
+		}
+		if updates.NonBreaking != "" {
+			target = updates.NonBreaking
+		}
+		if !allowMajor || updates.Breaking == "" {
+			break
+		}
+		target = updates.Breaking
+		version = updates.Breaking
+	}
+
+	return current, target, nil
+}
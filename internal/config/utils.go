@@ -39,7 +39,7 @@ func LoadConfigFile(dataStruct interface{}, meta *Metadata, configFilePath strin
 	buf, err := os.ReadFile(absPath)
 	if err == nil {
 		switch ext := path.Ext(absPath); ext {
-		case ".yaml", ".yml":
+		case ".yaml", ".yml", ".lock":
 			if err := yaml.Unmarshal(buf, dataStruct); err != nil {
 				return err
 			}
@@ -66,6 +66,8 @@ func LoadConfigFile(dataStruct interface{}, meta *Metadata, configFilePath strin
 }
 
 // SaveConfigFile validates, serializes and saves configuration to a file.
+// Permissions of an existing file are kept and symbolic links are followed,
+// new files are created with 0644 permissions.
 func SaveConfigFile(data interface{}, configFilePath string) error {
 	log.Spamf(`Saving file "%s"...`, configFilePath)
 
@@ -73,13 +75,25 @@ func SaveConfigFile(data interface{}, configFilePath string) error {
 		return fmt.Errorf(`failed to generate valid config for %s: %s`, configFilePath, err)
 	}
 
-	file, err := os.OpenFile(configFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0o644)
+	// the file a symbolic link points to is replaced, not the link itself
+	if realPath, err := filepath.EvalSymlinks(configFilePath); err == nil {
+		configFilePath = realPath
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(configFilePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	// write to a temporary file first and rename it afterwards, so the file is
+	// never left truncated or partially written
+	file, err := os.CreateTemp(filepath.Dir(configFilePath), "."+filepath.Base(configFilePath)+".tmp-")
 	if err != nil {
 		return err
 	}
+	defer func() { _ = os.Remove(file.Name()) }()
 
 	switch ext := path.Ext(configFilePath); ext {
-	case ".yaml", ".yml":
+	case ".yaml", ".yml", ".lock":
 		encoder := yaml.NewEncoder(file)
 		encoder.SetIndent(2)
 		err = encoder.Encode(data)
@@ -88,14 +102,22 @@ func SaveConfigFile(data interface{}, configFilePath string) error {
 		encoder.SetIndent("", "  ")
 		err = encoder.Encode(data)
 	default:
-		return fmt.Errorf(`unsupported extension "%s" of file: %s`, ext, configFilePath)
+		err = fmt.Errorf(`unsupported extension "%s" of file: %s`, ext, configFilePath)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
 	}
-
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return os.Rename(file.Name(), configFilePath)
 }
 
 func validate(data interface{}, dir string) error {
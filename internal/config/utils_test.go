@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type testConfig struct {
+	Name string `yaml:"name"`
+}
+
+func TestSaveConfigFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions and symbolic links aren't supported on Windows")
+	}
+	dir := t.TempDir()
+
+	newFile := filepath.Join(dir, "new.yaml")
+	if err := SaveConfigFile(&testConfig{Name: "new"}, newFile); err != nil {
+		t.Fatalf("SaveConfigFile() error = %v", err)
+	}
+	if info, err := os.Stat(newFile); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o644 {
+		t.Errorf("new file has mode %v, want 0644", info.Mode().Perm())
+	}
+
+	// file with credentials, linked from another directory
+	realFile := filepath.Join(dir, "real", "config.yaml")
+	linkFile := filepath.Join(dir, "config.yaml")
+	_ = os.MkdirAll(filepath.Dir(realFile), 0o755)
+	_ = os.WriteFile(realFile, []byte("name: old\n"), 0o600)
+	if err := os.Symlink(realFile, linkFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveConfigFile(&testConfig{Name: "updated"}, linkFile); err != nil {
+		t.Fatalf("SaveConfigFile() error = %v", err)
+	}
+	if info, err := os.Lstat(linkFile); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symbolic link has been replaced (error = %v)", err)
+	}
+	if info, err := os.Stat(realFile); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("linked file has mode %v, want 0600", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(realFile); string(data) != "name: updated\n" {
+		t.Errorf("linked file contains %q, want updated config", data)
+	}
+}
@@ -1,10 +1,20 @@
 package context
 
-import "strings"
+import (
+	stdcontext "context"
+	"fmt"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/settings"
+)
 
 type CLIContext struct {
-	Config CLIConfig
-	Paths  Paths
+	Config   CLIConfig
+	Paths    Paths
+	Settings *settings.Settings
+	// Context is cancelled when klio is interrupted (e.g. with Ctrl-C), it
+	// may be nil.
+	Context stdcontext.Context
 }
 
 type CLIConfig struct {
@@ -12,14 +22,18 @@ type CLIConfig struct {
 	Description           string
 	Version               string
 	ProjectConfigFileName string
+	ProjectLockFileName   string
 	InstallDirName        string
 	DefaultRegistry       string
 }
 
 type Paths struct {
 	ProjectConfigFile string
+	ProjectLockFile   string
 	ProjectInstallDir string
 	GlobalInstallDir  string
+	CacheDir          string
+	UserConfigFile    string
 }
 
 func Initialize(cfg CLIConfig) (CLIContext, error) {
@@ -28,9 +42,15 @@ func Initialize(cfg CLIConfig) (CLIContext, error) {
 		return CLIContext{}, err
 	}
 
+	userSettings, err := settings.Load(paths.UserConfigFile)
+	if err != nil {
+		return CLIContext{}, fmt.Errorf("cannot load %s: %s", paths.UserConfigFile, err)
+	}
+
 	return CLIContext{
-		Config: cfg,
-		Paths:  paths,
+		Config:   cfg,
+		Paths:    paths,
+		Settings: userSettings,
 	}, nil
 }
 
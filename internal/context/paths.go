@@ -8,6 +8,11 @@ import (
 	"path/filepath"
 )
 
+const (
+	cacheDirName       = "cache"
+	userConfigFileName = "config.yaml"
+)
+
 func assemblePaths(cfg CLIConfig) (Paths, error) {
 	homeDir, err := getHomeDirPath()
 	if err != nil {
@@ -19,13 +24,27 @@ func assemblePaths(cfg CLIConfig) (Paths, error) {
 		return Paths{}, err
 	}
 
+	globalInstallDir := path.Join(homeDir, cfg.InstallDirName)
+
 	return Paths{
 		ProjectConfigFile: path.Join(projectDir, cfg.ProjectConfigFileName),
+		ProjectLockFile:   path.Join(projectDir, cfg.ProjectLockFileName),
 		ProjectInstallDir: path.Join(projectDir, cfg.InstallDirName),
-		GlobalInstallDir:  path.Join(homeDir, cfg.InstallDirName),
+		GlobalInstallDir:  globalInstallDir,
+		CacheDir:          getCacheDir(cfg.CommandName, globalInstallDir),
+		UserConfigFile:    path.Join(globalInstallDir, userConfigFileName),
 	}, nil
 }
 
+// getCacheDir returns directory for caching downloaded files. It respects
+// XDG_CACHE_HOME, if it is set.
+func getCacheDir(commandName string, globalInstallDir string) string {
+	if cacheHome := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(cacheHome) {
+		return path.Join(cacheHome, commandName)
+	}
+	return path.Join(globalInstallDir, cacheDirName)
+}
+
 // getHomeDirPath returns home directory of current user.
 func getHomeDirPath() (string, error) {
 	currentUser, err := user.Current()
@@ -30,15 +30,31 @@ type DependenciesIndex struct {
 	Entries    []DependenciesIndexEntry `json:"entries"`
 }
 
+// LinkKind is a kind of entries registering directories with commands under
+// development instead of installed archives.
+const LinkKind = "link"
+
 type DependenciesIndexEntry struct {
-	Alias    string `json:"alias"`
-	Registry string `json:"registry"`
-	Name     string `json:"name"`
-	Version  string `json:"version"`
-	OS       string `json:"os"`
-	Arch     string `json:"arch"`
-	Checksum string `json:"checksum"`
-	Path     string `json:"path"`
+	Alias    string `json:"alias" yaml:"alias"`
+	Registry string `json:"registry" yaml:"registry"`
+	Name     string `json:"name" yaml:"name"`
+	Version  string `json:"version" yaml:"version"`
+	URL      string `json:"url,omitempty" yaml:"url,omitempty"`
+	OS       string `json:"os" yaml:"os,omitempty"`
+	Arch     string `json:"arch" yaml:"arch,omitempty"`
+	Checksum string `json:"checksum" yaml:"checksum"`
+	Path     string `json:"path" yaml:"-"`
+	// Kind is empty for installed commands and LinkKind for linked ones, Path
+	// of linked commands is absolute.
+	Kind string `json:"kind,omitempty" yaml:"-"`
+	// InstallDir is a directory with dependencies.json listing the entry, it
+	// is set for entries returned by GetInstalledCommands.
+	InstallDir string `json:"-" yaml:"-"`
+}
+
+// IsLink checks whether the entry is a linked command.
+func (di *DependenciesIndexEntry) IsLink() bool {
+	return di.Kind == LinkKind
 }
 
 func (di *DependenciesIndexEntry) ToDependency() Dependency {
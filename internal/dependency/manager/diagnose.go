@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/g2a-com/klio/internal/cmd"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/registry"
+	"github.com/g2a-com/klio/internal/lock"
+	"github.com/spf13/afero"
+)
+
+// ProblemKind identifies a kind of problem found by CheckInstallDir.
+type ProblemKind string
+
+const (
+	// InvalidIndexProblem means that dependencies.json can't be read.
+	InvalidIndexProblem ProblemKind = "invalid-index"
+	// MissingDirectoryProblem means that directory of an index entry doesn't exist.
+	MissingDirectoryProblem ProblemKind = "missing-directory"
+	// InvalidCommandProblem means that command.yaml is missing or invalid.
+	InvalidCommandProblem ProblemKind = "invalid-command"
+	// UnsupportedAPIVersionProblem means that command requires a newer klio.
+	UnsupportedAPIVersionProblem ProblemKind = "unsupported-api-version"
+	// NotExecutableProblem means that binPath of a command isn't executable.
+	NotExecutableProblem ProblemKind = "not-executable"
+	// PlatformMismatchProblem means that command was built for other OS or architecture.
+	PlatformMismatchProblem ProblemKind = "platform-mismatch"
+	// UnusedDirectoryProblem means that directory isn't listed in dependencies.json.
+	UnusedDirectoryProblem ProblemKind = "unused-directory"
+	// StaleLockProblem means that lock file was left by a process which doesn't run anymore.
+	StaleLockProblem ProblemKind = "stale-lock"
+	// HeldLockProblem means that lock file is held by a running process.
+	HeldLockProblem ProblemKind = "held-lock"
+)
+
+// Problem describes an inconsistency found in an installation directory.
+type Problem struct {
+	Kind ProblemKind
+	// Entry is nil for problems which aren't related to any installed command.
+	Entry *dependency.DependenciesIndexEntry
+	// Path is an absolute path of the file or directory causing the problem.
+	Path    string
+	Message string
+}
+
+// CheckInstallDir compares dependencies.json in the installDir directory with
+// files on disk. It returns entries of the index (with absolute paths, like
+// GetInstalledCommands) and found problems. Nothing is modified.
+func (mgr *Manager) CheckInstallDir(installDir string) ([]dependency.DependenciesIndexEntry, []Problem) {
+	var problems []Problem
+
+	if status, err := lock.Inspect(filepath.Join(installDir, indexLockFile)); err != nil {
+		problems = append(problems, Problem{Kind: StaleLockProblem, Path: filepath.Join(installDir, indexLockFile), Message: err.Error()})
+	} else if status != nil && status.Held {
+		problems = append(problems, Problem{Kind: HeldLockProblem, Path: status.Path, Message: fmt.Sprintf("held by %s for %s", status.Owner, status.Age.Round(time.Second))})
+	} else if status != nil {
+		problems = append(problems, Problem{Kind: StaleLockProblem, Path: status.Path, Message: fmt.Sprintf("left by %s", status.Owner)})
+	}
+
+	entries, err := mgr.getIndexEntries(installDir)
+	if err != nil {
+		problems = append(problems, Problem{Kind: InvalidIndexProblem, Path: filepath.Join(installDir, indexFileName), Message: err.Error()})
+		return nil, problems
+	}
+
+	usedDirs := map[string]bool{}
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.IsLink() {
+			entry.Path = filepath.Join(installDir, entry.Path)
+			usedDirs[filepath.Clean(entry.Path)] = true
+		}
+		entry.InstallDir = installDir
+		problems = append(problems, mgr.checkEntry(entry)...)
+	}
+
+	dependenciesDir := filepath.Join(installDir, dependenciesDirectoryName)
+	files, _ := afero.ReadDir(mgr.os, dependenciesDir)
+	for _, file := range files {
+		path := filepath.Join(dependenciesDir, file.Name())
+		switch {
+		case strings.HasPrefix(file.Name(), stagingDirPrefix):
+			problems = append(problems, Problem{Kind: UnusedDirectoryProblem, Path: path, Message: "left by an interrupted installation"})
+		case !usedDirs[path]:
+			problems = append(problems, Problem{Kind: UnusedDirectoryProblem, Path: path, Message: fmt.Sprintf("not listed in %s", indexFileName)})
+		}
+	}
+
+	return entries, problems
+}
+
+// checkEntry looks for problems with a single installed command.
+func (mgr *Manager) checkEntry(entry *dependency.DependenciesIndexEntry) []Problem {
+	if info, err := mgr.os.Stat(entry.Path); err != nil || !info.IsDir() {
+		return []Problem{{Kind: MissingDirectoryProblem, Entry: entry, Path: entry.Path, Message: "directory doesn't exist"}}
+	}
+
+	var problems []Problem
+	if (entry.OS != "" && entry.OS != runtime.GOOS) || (entry.Arch != "" && entry.Arch != runtime.GOARCH) {
+		problems = append(problems, Problem{
+			Kind:    PlatformMismatchProblem,
+			Entry:   entry,
+			Path:    entry.Path,
+			Message: fmt.Sprintf("built for %s/%s, but running on %s/%s", entry.OS, entry.Arch, runtime.GOOS, runtime.GOARCH),
+		})
+	}
+
+	commandConfig, err := mgr.verifyCommandConfig(entry.Path)
+	if err != nil {
+		return append(problems, Problem{Kind: InvalidCommandProblem, Entry: entry, Path: filepath.Join(entry.Path, commandConfigFileName), Message: err.Error()})
+	}
+	if !cmd.IsSupportedAPIVersion(commandConfig.APIVersion) {
+		problems = append(problems, Problem{
+			Kind:    UnsupportedAPIVersionProblem,
+			Entry:   entry,
+			Path:    filepath.Join(entry.Path, commandConfigFileName),
+			Message: fmt.Sprintf(`apiVersion "%s" isn't supported`, commandConfig.APIVersion),
+		})
+	}
+	// Windows doesn't use permission bits to mark executables
+	binPath := filepath.Join(entry.Path, commandConfig.BinPath)
+	if info, err := mgr.os.Stat(binPath); err == nil && runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+		problems = append(problems, Problem{Kind: NotExecutableProblem, Entry: entry, Path: binPath, Message: "binPath isn't executable"})
+	}
+
+	return problems
+}
+
+// CheckRegistry downloads and parses index of the registry with the given
+// url. Unlike other methods, it ignores mirrors, fallbacks and cached indexes,
+// so it reports whether the registry itself is reachable.
+func (mgr *Manager) CheckRegistry(url string) error {
+	var depRegistry registry.Registry
+	if strings.HasPrefix(url, "file://") {
+		depRegistry = registry.NewLocal(url)
+	} else {
+		depRegistry = registry.NewRemote(url, registry.Options{
+			Client:   mgr.httpDownloadClient,
+			Download: mgr.DownloadOptions,
+			Context:  mgr.getContext(),
+		})
+	}
+	return depRegistry.Update()
+}
@@ -9,3 +9,61 @@ type CantFindExactVersionMatchError struct {
 func (e *CantFindExactVersionMatchError) Error() string {
 	return fmt.Sprintf("cannot find %s@%s in %s", e.depName, e.depVersion, e.depRegistry)
 }
+
+type LockedChecksumMismatchError struct {
+	depName, depVersion, lockedChecksum, checksum string
+}
+
+func (e *LockedChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum of %s@%s (%s) is different from the locked one (%s)", e.depName, e.depVersion, e.checksum, e.lockedChecksum)
+}
+
+type ArchiveNotAvailableOfflineError struct {
+	depName, depVersion, url string
+}
+
+func (e *ArchiveNotAvailableOfflineError) Error() string {
+	return fmt.Sprintf("%s@%s is not available offline, archive %s has not been downloaded yet", e.depName, e.depVersion, e.url)
+}
+
+type UnsignedArchiveError struct {
+	depName, depVersion, depRegistry string
+}
+
+func (e *UnsignedArchiveError) Error() string {
+	return fmt.Sprintf("%s@%s is not signed, but %s requires signatures", e.depName, e.depVersion, e.depRegistry)
+}
+
+type InvalidSignatureError struct {
+	depName, depVersion string
+	err                 error
+}
+
+func (e *InvalidSignatureError) Error() string {
+	return fmt.Sprintf("cannot verify signature of %s@%s: %s", e.depName, e.depVersion, e.err)
+}
+
+type MissingChecksumError struct {
+	depName, depVersion, depRegistry string
+}
+
+func (e *MissingChecksumError) Error() string {
+	return fmt.Sprintf("%s@%s has no checksum in %s, but checksums are required", e.depName, e.depVersion, e.depRegistry)
+}
+
+type ChecksumChangedError struct {
+	depName, depVersion, recordedChecksum, checksum string
+}
+
+func (e *ChecksumChangedError) Error() string {
+	return fmt.Sprintf("checksum of %s@%s (%s) is different from the one recorded when it was installed for the first time (%s), the archive may have been tampered with", e.depName, e.depVersion, e.checksum, e.recordedChecksum)
+}
+
+type InvalidCommandError struct {
+	depName, depVersion string
+	err                 error
+}
+
+func (e *InvalidCommandError) Error() string {
+	return fmt.Sprintf("%s@%s is not a valid command: %s", e.depName, e.depVersion, e.err)
+}
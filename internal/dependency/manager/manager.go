@@ -1,23 +1,34 @@
 package manager
 
 import (
+	stdcontext "context"
 	"crypto/sha256"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/g2a-com/klio/internal/archive"
+	"github.com/g2a-com/klio/internal/auth"
+	"github.com/g2a-com/klio/internal/cache"
+	"github.com/g2a-com/klio/internal/cmd"
 	"github.com/g2a-com/klio/internal/context"
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/registry"
+	"github.com/g2a-com/klio/internal/download"
+	"github.com/g2a-com/klio/internal/env"
 	"github.com/g2a-com/klio/internal/lock"
 	"github.com/g2a-com/klio/internal/log"
-	"github.com/g2a-com/klio/internal/tarball"
-	"github.com/schollz/progressbar/v3"
+	"github.com/g2a-com/klio/internal/signature"
 	"github.com/spf13/afero"
-	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -25,6 +36,11 @@ const (
 	indexLockFile             = "dependencies.lock"
 	dependenciesDirectoryName = "dependencies"
 	defaultDirPermissions     = 0o755
+	registriesCacheDirName    = "registries"
+	commandConfigFileName     = "command.yaml"
+	stagingDirPrefix          = ".staging-"
+	// staging directories older than that are assumed to be abandoned
+	staleStagingDirAge = 24 * time.Hour
 )
 
 type Updates struct {
@@ -33,12 +49,55 @@ type Updates struct {
 }
 
 type Manager struct {
-	DefaultRegistry        string
-	registries             map[string]registry.Registry
+	DefaultRegistry string
+	// Locked lists artifacts to which dependencies are pinned (e.g. by the
+	// project lock file). Installation fails if downloaded archive doesn't
+	// match the checksum of the corresponding locked entry.
+	Locked []dependency.DependenciesIndexEntry
+	// Cache stores downloaded archives. Archives with checksums known in
+	// advance are taken from the cache instead of being downloaded again.
+	Cache *cache.Cache
+	// Offline makes the manager use only cached registry indexes and archives.
+	Offline bool
+	// TrustedKeys lists public keys by registry URL prefix. Archives from
+	// registries with trusted keys must be signed with one of them.
+	TrustedKeys map[string][]string
+	// RequireChecksums makes the manager refuse registry entries without checksums.
+	RequireChecksums bool
+	// TrustOnFirstUse makes the manager compare checksums of downloaded
+	// archives with the ones recorded on the first download of the same
+	// command version (in the cache directory or in dependencies.json).
+	TrustOnFirstUse bool
+	// ArchiveLimits restrict content of extracted archives.
+	ArchiveLimits archive.Limits
+	// Mirrors maps URL prefixes of registries and archives to URL prefixes of
+	// their mirrors. Mirrors are tried before the original URLs.
+	Mirrors map[string]string
+	// FallbackRegistries are tried in order if a registry can't be loaded.
+	FallbackRegistries []string
+	// DownloadOptions configure timeouts and retries of HTTP requests.
+	DownloadOptions download.Options
+	// LockOptions configure waiting for locks of installation directories.
+	LockOptions lock.Options
+	// Context cancels waiting for locks and downloads, context.Background() is
+	// used if it is nil.
+	Context    stdcontext.Context
+	registries map[string]registry.Registry
+	// registryErrors stores errors of registries which couldn't be loaded, so
+	// every caller gets the same error instead of loading them again.
+	registryErrors  map[string]error
+	registriesMutex sync.Mutex
+	indexMutex      sync.Mutex
+	keyMutexes      sync.Map
+	// pendingDirs counts directories moved into place, but not added to
+	// dependencies.json yet, they mustn't be removed as unused ones.
+	pendingDirs            map[string]int
+	pendingDirsMutex       sync.Mutex
 	os                     afero.Fs
 	httpDownloadClient     *http.Client
 	dependencyIndexHandler dependency.IndexHandler
-	createLock             func(string) (lock.Lock, error)
+	createLock             func(string, lock.Options) (lock.Lock, error)
+	progress               *progress
 }
 
 // NewManager returns a new default Manager.
@@ -47,28 +106,116 @@ func NewManager() *Manager {
 		registries:             map[string]registry.Registry{},
 		os:                     afero.NewOsFs(),
 		dependencyIndexHandler: &dependency.LocalIndexHandler{},
-		httpDownloadClient:     http.DefaultClient,
+		httpDownloadClient:     &http.Client{Transport: download.NewTransport(download.DefaultOptions)},
 		createLock:             lock.New,
+		Offline:                IsOffline(),
+		ArchiveLimits:          archive.DefaultLimits,
+		LockOptions:            lock.DefaultOptions,
+		DownloadOptions:        download.DefaultOptions,
 	}
 }
 
+// NewManagerForContext returns a new Manager configured according to the CLI
+// context: it uses the default registry and the cache, and authenticates
+// requests using credentials from user settings.
+func NewManagerForContext(ctx context.CLIContext) *Manager {
+	mgr := NewManager()
+	mgr.Context = ctx.Context
+	mgr.DefaultRegistry = ctx.Config.DefaultRegistry
+	mgr.Cache = cache.New(afero.NewOsFs(), ctx.Paths.CacheDir)
+	if ctx.Settings != nil {
+		for _, r := range ctx.Settings.Registries {
+			mgr.AddTrustedKeys(r.URL, r.TrustedKeys)
+		}
+		mgr.Mirrors = ctx.Settings.Mirrors
+		mgr.FallbackRegistries = ctx.Settings.FallbackRegistries
+		mgr.RequireChecksums = ctx.Settings.RequireChecksums
+		mgr.TrustOnFirstUse = ctx.Settings.TrustOnFirstUse
+		if options := ctx.Settings.Download; options != nil {
+			if options.ConnectTimeout > 0 {
+				mgr.DownloadOptions.ConnectTimeout = options.ConnectTimeout
+			}
+			if options.ReadTimeout > 0 {
+				mgr.DownloadOptions.ReadTimeout = options.ReadTimeout
+			}
+			if options.Retries != nil {
+				mgr.DownloadOptions.Retries = *options.Retries
+			}
+		}
+		if ctx.Settings.LockTimeout > 0 {
+			mgr.LockOptions.Timeout = ctx.Settings.LockTimeout
+		}
+		if limits := ctx.Settings.ArchiveLimits; limits != nil {
+			if limits.MaxTotalSize > 0 {
+				mgr.ArchiveLimits.MaxTotalSize = limits.MaxTotalSize
+			}
+			if limits.MaxFileSize > 0 {
+				mgr.ArchiveLimits.MaxFileSize = limits.MaxFileSize
+			}
+			if limits.MaxEntries > 0 {
+				mgr.ArchiveLimits.MaxEntries = limits.MaxEntries
+			}
+		}
+	}
+	if timeout, ok := getLockTimeout(); ok {
+		mgr.LockOptions.Timeout = timeout
+	}
+	mgr.httpDownloadClient = auth.NewClient(auth.NewProvider(ctx.Settings), download.NewTransport(mgr.DownloadOptions))
+	return mgr
+}
+
+// getLockTimeout returns timeout of waiting for locks set with the
+// KLIO_LOCK_TIMEOUT environment variable.
+func getLockTimeout() (time.Duration, bool) {
+	timeoutStr, exists := os.LookupEnv(env.KLIO_LOCK_TIMEOUT)
+	if !exists {
+		return 0, false
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil || timeout < 0 {
+		log.Warnf("Could not parse duration value of %s: %s", env.KLIO_LOCK_TIMEOUT, timeoutStr)
+		return 0, false
+	}
+	return timeout, true
+}
+
+// AddTrustedKeys adds public keys trusted by registries with URLs starting
+// with the registryPrefix.
+func (mgr *Manager) AddTrustedKeys(registryPrefix string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if mgr.TrustedKeys == nil {
+		mgr.TrustedKeys = map[string][]string{}
+	}
+	mgr.TrustedKeys[registryPrefix] = append(mgr.TrustedKeys[registryPrefix], keys...)
+}
+
+// IsOffline checks whether offline mode is enabled with the KLIO_OFFLINE
+// environment variable.
+func IsOffline() bool {
+	offlineStr, exists := os.LookupEnv(env.KLIO_OFFLINE)
+	if !exists {
+		return false
+	}
+	offline, err := strconv.ParseBool(offlineStr)
+	if err != nil {
+		log.Warnf("Could not parse boolean value of %s, err: %s", env.KLIO_OFFLINE, err.Error())
+		return false
+	}
+	return offline
+}
+
 // GetUpdateFor gets updates for given dependency dep.
 // If error doesn't occur, both major and minor updates are returned.
 func (mgr *Manager) GetUpdateFor(dep dependency.Dependency) (Updates, error) {
 	// Initialize depRegistry
-	if _, ok := mgr.registries[dep.Registry]; !ok {
-		if strings.HasPrefix(dep.Registry, "file://") {
-			mgr.registries[dep.Registry] = registry.NewLocal(dep.Registry)
-		} else {
-			mgr.registries[dep.Registry] = registry.NewRemote(dep.Registry)
-		}
-		if err := mgr.registries[dep.Registry].Update(); err != nil {
-			return Updates{}, err
-		}
+	depRegistry, err := mgr.getRegistry(dep.Registry)
+	if err != nil {
+		return Updates{}, err
 	}
 
 	// Find versions
-	depRegistry := mgr.registries[dep.Registry]
 
 	nonBreaking, err := depRegistry.GetHighestNonBreaking(dep)
 	if err != nil {
@@ -92,123 +239,505 @@ func (mgr *Manager) GetUpdateFor(dep dependency.Dependency) (Updates, error) {
 	return updates, nil
 }
 
+// GetMatchingVersionFor returns the highest version of dependency dep which
+// satisfies its version. Empty string is returned if there is no such version.
+func (mgr *Manager) GetMatchingVersionFor(dep dependency.Dependency) (string, error) {
+	depRegistry, err := mgr.getRegistry(dep.Registry)
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := depRegistry.GetExactMatch(dep)
+	if err != nil || entry == nil {
+		return "", err
+	}
+
+	return entry.Version, nil
+}
+
+// GetRegistryEntries returns all entries of the registry with the given url,
+// the default registry is used if url is empty.
+func (mgr *Manager) GetRegistryEntries(url string) ([]registry.Entry, error) {
+	if url == "" {
+		url = mgr.DefaultRegistry
+	}
+	depRegistry, err := mgr.getRegistry(url)
+	if err != nil {
+		return nil, err
+	}
+	return depRegistry.GetEntries(), nil
+}
+
 // InstallDependency installs a single dependency in the installDir directory.
-// Dependency metadata is provided in dep.
+// Dependency metadata is provided in dep. It is safe to install multiple
+// dependencies concurrently, only updating dependencies.json is serialized.
 func (mgr *Manager) InstallDependency(dep *dependency.Dependency, installDir string) (*dependency.DependenciesIndexEntry, error) {
-	// == Acquire lock for updating dependencies.json ==
 	// make sure main install dir exists (necessary for lockfile setup)
 	if err := mgr.os.MkdirAll(installDir, defaultDirPermissions); err != nil {
 		log.Fatalf("unable to create directory: %s due to %s", installDir, err)
 	}
-	installLock, err := mgr.createLock(filepath.Join(installDir, indexLockFile))
+
+	dependencyIndexEntry, err := mgr.fetchDependency(dep, installDir)
 	if err != nil {
 		return nil, err
 	}
-	if err := installLock.Acquire(); err != nil {
+	defer mgr.setPending(filepath.Join(installDir, dependencyIndexEntry.Path), -1)
+
+	if err := mgr.addToIndex(*dependencyIndexEntry, installDir); err != nil {
 		return nil, err
 	}
-	defer func() {
-		err = installLock.Release()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
 
+	return dependencyIndexEntry, nil
+}
+
+// fetchDependency downloads and extracts a dependency into the installDir
+// directory, without registering it in dependencies.json.
+func (mgr *Manager) fetchDependency(dep *dependency.Dependency, installDir string) (*dependency.DependenciesIndexEntry, error) {
 	// == Initialize registry ==
 	dep.SetDefaults(mgr.DefaultRegistry)
-	if _, ok := mgr.registries[dep.Registry]; !ok {
-		if strings.HasPrefix(dep.Registry, "file://") {
-			mgr.registries[dep.Registry] = registry.NewLocal(dep.Registry)
-		} else {
-			mgr.registries[dep.Registry] = registry.NewRemote(dep.Registry)
-		}
-		if err := mgr.registries[dep.Registry].Update(); err != nil {
-			return nil, err
-		}
+	depRegistry, err := mgr.getRegistry(dep.Registry)
+	if err != nil {
+		return nil, err
 	}
 
 	// == Search for a suitable version ==
-	registryEntry, _ := mgr.registries[dep.Registry].GetExactMatch(*dep)
+	registryEntry, _ := depRegistry.GetExactMatch(*dep)
 	if registryEntry == nil {
 		return nil, &CantFindExactVersionMatchError{dep.Name, dep.Version, dep.Registry}
 	}
 
-	// == Download tarball to a temporary file ==
-	tempFile, err := afero.TempFile(mgr.os, "", "klio-")
+	// == Get archive from the cache or download it to a temporary file ==
+	if mgr.RequireChecksums && registryEntry.Checksum == "" {
+		return nil, &MissingChecksumError{dep.Name, registryEntry.Version, dep.Registry}
+	}
+
+	// == Find checksums recorded by previous installations ==
+	// checksums of installed commands are forgotten when they are upgraded
+	// or removed, trusted checksums are kept in the cache directory forever
+	locked := findRecorded(mgr.Locked, *dep, *registryEntry)
+	trustedKey := getTrustedChecksumKey(dep.Registry, *registryEntry)
+	var recorded, trusted string
+	if mgr.TrustOnFirstUse {
+		if trusted, err = mgr.findTrustedChecksum(trustedKey); err != nil {
+			return nil, err
+		}
+		recorded = trusted
+		if recorded == "" {
+			entries, err := mgr.getIndexEntries(installDir)
+			if err != nil {
+				return nil, err
+			}
+			if entry := findRecorded(entries, *dep, *registryEntry); entry != nil {
+				recorded = entry.Checksum
+			}
+		}
+	}
+	expectedChecksum := registryEntry.Checksum
+	if expectedChecksum == "" && locked != nil {
+		expectedChecksum = locked.Checksum
+	}
+	if expectedChecksum == "" {
+		expectedChecksum = recorded
+	}
+	// == Verify checksum and signature ==
+	// archives from mirrors are verified before they are accepted, so the
+	// original URL is used if a mirror serves a stale or corrupted archive
+	verify := func(file afero.File, checksum string) error {
+		if registryEntry.Checksum != "" && registryEntry.Checksum != checksum {
+			return fmt.Errorf(`checksum of the archive (%s) is different from the one specified in the regsitry (%s)`, checksum, registryEntry.Checksum)
+		}
+		if locked != nil && locked.Checksum != checksum {
+			return &LockedChecksumMismatchError{dep.Name, registryEntry.Version, locked.Checksum, checksum}
+		}
+		if recorded != "" && recorded != checksum {
+			return &ChecksumChangedError{dep.Name, registryEntry.Version, recorded, checksum}
+		}
+		return mgr.verifySignature(dep, registryEntry, file)
+	}
+	file, checksum, err := mgr.getArchive(registryEntry, expectedChecksum, verify)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = mgr.os.Remove(tempFile.Name())
-	}()
-	checksum, err := downloadFile(mgr.httpDownloadClient, registryEntry.URL, tempFile)
+	defer func() { _ = file.Close() }()
+	mgr.addToCache(file, checksum)
+	if mgr.TrustOnFirstUse && trusted == "" {
+		if err := mgr.addTrustedChecksum(trustedKey, checksum); err != nil {
+			log.Warnf("Cannot record checksum of %s@%s: %s", dep.Name, registryEntry.Version, err)
+		}
+	}
+
+	// == Extract archive into a staging directory ==
+	// dependency is moved into place only once it is fully extracted and
+	// verified, so failures never leave half-written directories behind
+	dependenciesDir := filepath.Join(installDir, dependenciesDirectoryName)
+	if err := mgr.os.MkdirAll(dependenciesDir, defaultDirPermissions); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %s due to %s", dependenciesDir, err)
+	}
+	mgr.removeStaleStagingDirs(dependenciesDir)
+	stagingDir, err := afero.TempDir(mgr.os, dependenciesDir, stagingDirPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create directory in: %s due to %s", dependenciesDir, err)
+	}
+	defer func() { _ = mgr.os.RemoveAll(stagingDir) }()
+	if err := mgr.os.Chmod(stagingDir, defaultDirPermissions); err != nil {
+		return nil, err
+	}
+
+	format, err := archive.DetectFormat(registryEntry.Format, registryEntry.URL, file)
+	if err != nil {
+		return nil, err
+	}
+	if format == archive.FormatBinary {
+		err = mgr.installBinary(file, registryEntry, stagingDir)
+	} else {
+		err = archive.Extract(file, format, mgr.os, stagingDir, mgr.ArchiveLimits)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// == Verify checksum ==
-	if registryEntry.Checksum != "" && registryEntry.Checksum != checksum {
-		return nil, fmt.Errorf(`checksum of the archive (%s) is different from the one specified in the regsitry (%s)`, checksum, registryEntry.Checksum)
+	// == Verify command.yaml ==
+	if _, err := mgr.verifyCommandConfig(stagingDir); err != nil {
+		return nil, &InvalidCommandError{dep.Name, registryEntry.Version, err}
 	}
 
-	// == Prepare directory to install the dependency ==
+	// == Move the dependency into place ==
+	// the same archive may be installed concurrently under different aliases
 	outputRelPath := filepath.Join(dependenciesDirectoryName, checksum)
 	outputAbsPath := filepath.Join(installDir, outputRelPath)
-	if err := mgr.os.RemoveAll(outputAbsPath); err != nil {
-		return nil, fmt.Errorf("unable to remove directory: %s due to %s", outputAbsPath, err)
+	unlock := mgr.lockKey(outputAbsPath)
+	defer unlock()
+	if err := mgr.moveIntoPlace(stagingDir, outputAbsPath); err != nil {
+		return nil, err
 	}
-	if err := mgr.os.MkdirAll(outputAbsPath, defaultDirPermissions); err != nil {
-		return nil, fmt.Errorf("unable to create directory: %s due to %s", outputAbsPath, err)
+	mgr.setPending(outputAbsPath, 1)
+
+	dep.Version = registryEntry.Version
+
+	return &dependency.DependenciesIndexEntry{
+		Alias:    dep.Alias,
+		Registry: dep.Registry,
+		Name:     dep.Name,
+		Version:  registryEntry.Version,
+		URL:      registryEntry.URL,
+		OS:       registryEntry.OS,
+		Arch:     registryEntry.Arch,
+		Checksum: checksum,
+		Path:     outputRelPath,
+	}, nil
+}
+
+// verifySignature checks signature of the archive if the registry has any
+// trusted keys.
+func (mgr *Manager) verifySignature(dep *dependency.Dependency, registryEntry *registry.Entry, archive io.ReadSeeker) error {
+	var trustedKeys []string
+	if registryURL, err := url.Parse(dep.Registry); err == nil {
+		for prefix, keys := range mgr.TrustedKeys {
+			if auth.HasURLPrefix(registryURL, prefix) {
+				trustedKeys = append(trustedKeys, keys...)
+			}
+		}
+	}
+	if len(trustedKeys) == 0 {
+		return nil
 	}
 
-	// == Extract tarball into the installation directory ==
-	_, _ = tempFile.Seek(0, io.SeekStart)
-	if err := tarball.Extract(tempFile, mgr.os, outputAbsPath); err != nil {
-		return nil, err
+	if registryEntry.Signature == "" {
+		return &UnsignedArchiveError{dep.Name, registryEntry.Version, dep.Registry}
+	}
+	_, _ = archive.Seek(0, io.SeekStart)
+	if err := signature.Verify(archive, registryEntry.Signature, trustedKeys); err != nil {
+		return &InvalidSignatureError{dep.Name, registryEntry.Version, err}
+	}
+	log.Debugf("Verified signature of %s@%s", dep.Name, registryEntry.Version)
+
+	return nil
+}
+
+// getArchive returns archive described by the registry entry together with
+// its checksum. Archive is taken from the cache if its checksum is known in
+// advance, otherwise it is downloaded to a temporary file removed on Close.
+func (mgr *Manager) getArchive(registryEntry *registry.Entry, checksum string, verify func(file afero.File, checksum string) error) (afero.File, string, error) {
+	if mgr.Cache != nil && checksum != "" {
+		if file, ok := mgr.Cache.Open(checksum); ok {
+			log.Verbosef("Using cached %s", auth.RedactURL(registryEntry.URL))
+			if mgr.progress != nil {
+				mgr.progress.done()
+			}
+			if err := verify(file, checksum); err != nil {
+				_ = file.Close()
+				return nil, "", err
+			}
+			return file, checksum, nil
+		}
+	}
+	if mgr.Offline {
+		return nil, "", &ArchiveNotAvailableOfflineError{registryEntry.Name, registryEntry.Version, auth.RedactURL(registryEntry.URL)}
+	}
+
+	tempFile, err := afero.TempFile(mgr.os, "", "klio-")
+	if err != nil {
+		return nil, "", err
+	}
+	archive := &tempArchive{File: tempFile, os: mgr.os}
+	if mgr.progress != nil {
+		defer mgr.progress.done()
+	}
+	for _, url := range mgr.getMirroredURLs(registryEntry.URL) {
+		if checksum, err = mgr.downloadFile(url, tempFile); err == nil {
+			if err = verify(tempFile, checksum); err == nil {
+				break
+			}
+		}
+		log.Debugf("Cannot download %s: %s", auth.RedactURL(url), err)
+	}
+	if err != nil {
+		_ = archive.Close()
+		return nil, "", err
+	}
+
+	return archive, checksum, nil
+}
+
+// addToCache stores downloaded archive in the cache. Failures are not fatal,
+// since the archive is going to be installed anyway.
+func (mgr *Manager) addToCache(archive afero.File, checksum string) {
+	if mgr.Cache == nil {
+		return
+	}
+	if _, ok := archive.(*tempArchive); !ok {
+		return // archive comes from the cache already
+	}
+	_, _ = archive.Seek(0, io.SeekStart)
+	if err := mgr.Cache.Add(checksum, archive); err != nil {
+		log.Debugf("Cannot add %s to the cache: %s", checksum, err)
+	}
+}
+
+// verifyCommandConfig checks whether dir contains valid command.yaml file
+// pointing to an existing executable and returns its content.
+func (mgr *Manager) verifyCommandConfig(dir string) (*cmd.Config, error) {
+	data, err := afero.ReadFile(mgr.os, filepath.Join(dir, commandConfigFileName))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s", commandConfigFileName, err)
+	}
+	commandConfig := &cmd.Config{}
+	if err := yaml.Unmarshal(data, commandConfig); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %s", commandConfigFileName, err)
+	}
+	if commandConfig.Kind != "Command" {
+		return nil, fmt.Errorf(`%s has invalid kind "%s"`, commandConfigFileName, commandConfig.Kind)
+	}
+	if commandConfig.BinPath == "" {
+		return nil, fmt.Errorf("%s doesn't specify binPath", commandConfigFileName)
+	}
+	info, err := mgr.os.Stat(filepath.Join(dir, commandConfig.BinPath))
+	if err != nil {
+		return nil, fmt.Errorf("cannot find binPath %s: %s", commandConfig.BinPath, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("binPath %s is a directory", commandConfig.BinPath)
+	}
+	return commandConfig, nil
+}
+
+// moveIntoPlace moves srcDir into dstDir. Directories of dependencies are
+// named after checksums of their archives, so valid dstDir which exists
+// already has the same content and is reused: commands running from it keep
+// working, and concurrent processes installing the same archive don't fail.
+// Only invalid dstDir (e.g. modified by hand) is replaced.
+func (mgr *Manager) moveIntoPlace(srcDir string, dstDir string) error {
+	oldDir := ""
+	if _, err := mgr.os.Stat(dstDir); err == nil {
+		if _, err := mgr.verifyCommandConfig(dstDir); err == nil {
+			log.Debugf("Reusing directory %s", dstDir)
+			return nil
+		}
+		oldDir = fmt.Sprintf("%s%s%d", filepath.Join(filepath.Dir(dstDir), stagingDirPrefix), filepath.Base(dstDir), time.Now().UnixNano())
+		if err := mgr.os.Rename(dstDir, oldDir); err != nil {
+			// another process may have replaced it already
+			oldDir = ""
+		}
+	}
+
+	if err := mgr.os.Rename(srcDir, dstDir); err != nil {
+		// another process may have moved the same archive into place meanwhile
+		if _, verifyErr := mgr.verifyCommandConfig(dstDir); verifyErr == nil {
+			log.Debugf("Directory %s has been created by another process", dstDir)
+			err = nil
+		} else if oldDir != "" {
+			_ = mgr.os.Rename(oldDir, dstDir)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to move directory: %s due to %s", srcDir, err)
+		}
+	}
+
+	if oldDir != "" {
+		if err := mgr.os.RemoveAll(oldDir); err != nil {
+			log.Debugf("Cannot remove directory %s: %s", oldDir, err)
+		}
+	}
+	return nil
+}
+
+// removeStaleStagingDirs removes staging directories left behind by
+// installations which were interrupted.
+func (mgr *Manager) removeStaleStagingDirs(dir string) {
+	files, err := afero.ReadDir(mgr.os, dir)
+	if err != nil {
+		return
 	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), stagingDirPrefix) && time.Since(file.ModTime()) > staleStagingDirAge {
+			log.Debugf("Removing stale staging directory %s", file.Name())
+			_ = mgr.os.RemoveAll(filepath.Join(dir, file.Name()))
+		}
+	}
+}
+
+// installBinary installs a bare executable, together with command.yaml
+// synthesized from the registry entry, in the outputDir directory.
+func (mgr *Manager) installBinary(file archive.Source, registryEntry *registry.Entry, outputDir string) error {
+	binPath := path.Base(registryEntry.URL)
+	if parsedUrl, err := url.Parse(registryEntry.URL); err == nil {
+		binPath = path.Base(parsedUrl.Path)
+	}
+	if binPath == "." || binPath == "/" || strings.HasPrefix(binPath, "..") {
+		binPath = registryEntry.Name
+	}
+
+	if err := archive.WriteBinary(file, mgr.os, filepath.Join(outputDir, binPath), mgr.ArchiveLimits); err != nil {
+		return err
+	}
+
+	commandConfig, err := yaml.Marshal(&cmd.Config{
+		APIVersion:  "klio/v1",
+		Kind:        "Command",
+		BinPath:     binPath,
+		Description: registryEntry.Annotations["description"],
+		Version:     registryEntry.Version,
+	})
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(mgr.os, filepath.Join(outputDir, commandConfigFileName), commandConfig, 0o644)
+}
+
+// tempArchive is a downloaded archive removed when closed.
+type tempArchive struct {
+	afero.File
+	os afero.Fs
+}
+
+func (a *tempArchive) Close() error {
+	err := a.File.Close()
+	_ = a.os.Remove(a.File.Name())
+	return err
+}
+
+// addToIndex registers fetched dependency in dependencies.json and removes
+// directories of its previous versions.
+func (mgr *Manager) addToIndex(dependencyIndexEntry dependency.DependenciesIndexEntry, installDir string) error {
+	// == Acquire lock for updating dependencies.json ==
+	mgr.indexMutex.Lock()
+	defer mgr.indexMutex.Unlock()
+	installLock, err := mgr.createLock(filepath.Join(installDir, indexLockFile), mgr.LockOptions)
+	if err != nil {
+		return err
+	}
+	if err := installLock.Acquire(mgr.getContext()); err != nil {
+		return err
+	}
+	defer func() {
+		err = installLock.Release()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	// == Add dependency to dependencies.json ==
+	// directory could be removed by another process before the lock was acquired
+	if !dependencyIndexEntry.IsLink() {
+		if _, err := mgr.os.Stat(filepath.Join(installDir, dependencyIndexEntry.Path)); err != nil {
+			return fmt.Errorf("directory of %s has been removed by another process, try again: %s", dependencyIndexEntry.Alias, err)
+		}
+	}
 	indexFilePath := filepath.Join(installDir, indexFileName)
 	err = mgr.dependencyIndexHandler.LoadDependencyIndex(indexFilePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	var newEntries, oldEntries []dependency.DependenciesIndexEntry
 	for _, entry := range mgr.dependencyIndexHandler.GetEntries() {
-		if entry.Alias != dep.Alias {
+		if entry.Alias != dependencyIndexEntry.Alias {
 			newEntries = append(newEntries, entry)
-		} else if entry.Checksum != checksum {
+		} else {
 			oldEntries = append(oldEntries, entry)
 		}
 	}
+	newEntries = append(newEntries, dependencyIndexEntry)
+
+	mgr.dependencyIndexHandler.SetEntries(newEntries)
+	if err := mgr.dependencyIndexHandler.SaveDependencyIndex(); err != nil {
+		return err
+	}
 
 	// == Remove directories with dependencies that won't be used anymore ==
-	for _, entry := range oldEntries {
-		absPath := filepath.Join(installDir, entry.Path)
-		if err := mgr.os.RemoveAll(absPath); err != nil {
-			return nil, fmt.Errorf("unable to remove directory: %s due to %s", absPath, err)
+	return mgr.removeUnusedDirs(installDir, oldEntries, newEntries)
+}
+
+// removeUnusedDirs removes directories of the removed entries, unless they
+// are still referenced by any of the remaining entries.
+func (mgr *Manager) removeUnusedDirs(installDir string, removed []dependency.DependenciesIndexEntry, remaining []dependency.DependenciesIndexEntry) error {
+	for _, entry := range removed {
+		used := false
+		for _, other := range remaining {
+			used = used || filepath.Clean(other.Path) == filepath.Clean(entry.Path)
+		}
+		// linked directories don't belong to klio, they are never removed
+		if used || entry.Path == "" || entry.IsLink() {
+			continue
+		}
+		if err := mgr.removeUnusedDir(filepath.Join(installDir, entry.Path)); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	dep.Version = registryEntry.Version
+// removeUnusedDir removes the directory, unless a concurrent installation
+// has just moved it into place and is going to add it to dependencies.json.
+func (mgr *Manager) removeUnusedDir(absPath string) error {
+	unlock := mgr.lockKey(absPath)
+	defer unlock()
 
-	dependencyIndexEntry := dependency.DependenciesIndexEntry{
-		Alias:    dep.Alias,
-		Registry: dep.Registry,
-		Name:     dep.Name,
-		Version:  registryEntry.Version,
-		OS:       registryEntry.OS,
-		Arch:     registryEntry.Arch,
-		Checksum: registryEntry.Checksum,
-		Path:     outputRelPath,
+	mgr.pendingDirsMutex.Lock()
+	pending := mgr.pendingDirs[filepath.Clean(absPath)] > 0
+	mgr.pendingDirsMutex.Unlock()
+	if pending {
+		log.Debugf("Keeping directory %s used by a pending installation", absPath)
+		return nil
 	}
 
-	mgr.dependencyIndexHandler.SetEntries(append(newEntries, dependencyIndexEntry))
-	if err := mgr.dependencyIndexHandler.SaveDependencyIndex(); err != nil {
-		return nil, err
+	if err := mgr.os.RemoveAll(absPath); err != nil {
+		return fmt.Errorf("unable to remove directory: %s due to %s", absPath, err)
 	}
+	return nil
+}
 
-	return &dependencyIndexEntry, nil
+// setPending changes the number of installations which moved the directory
+// into place, but haven't added it to dependencies.json yet.
+func (mgr *Manager) setPending(absPath string, delta int) {
+	mgr.pendingDirsMutex.Lock()
+	defer mgr.pendingDirsMutex.Unlock()
+	if mgr.pendingDirs == nil {
+		mgr.pendingDirs = map[string]int{}
+	}
+	absPath = filepath.Clean(absPath)
+	if mgr.pendingDirs[absPath] += delta; mgr.pendingDirs[absPath] <= 0 {
+		delete(mgr.pendingDirs, absPath)
+	}
 }
 
 // RemoveDependency removes a single dependency in the installDir directory.
@@ -216,11 +745,11 @@ func (mgr *Manager) InstallDependency(dep *dependency.Dependency, installDir str
 func (mgr *Manager) RemoveDependency(dep *dependency.Dependency, installDir string) error {
 	// == Acquire lock for updating dependencies.json ==
 	// make sure main install dir exists (necessary for lockfile setup)
-	installLock, err := mgr.createLock(filepath.Join(installDir, indexLockFile))
+	installLock, err := mgr.createLock(filepath.Join(installDir, indexLockFile), mgr.LockOptions)
 	if err != nil {
 		return err
 	}
-	if err := installLock.Acquire(); err != nil {
+	if err := installLock.Acquire(mgr.getContext()); err != nil {
 		return err
 	}
 	defer func() { _ = installLock.Release() }()
@@ -246,35 +775,66 @@ func (mgr *Manager) RemoveDependency(dep *dependency.Dependency, installDir stri
 		return nil
 	}
 
-	// == Remove command from filesystem if it exists ==
-	for _, entryToRemove := range entriesToRemove {
-		shouldRemove := true
-		for _, entry := range mgr.dependencyIndexHandler.GetEntries() {
-			if entryToRemove.Alias != entry.Alias &&
-				entryToRemove.Name == entry.Name &&
-				entryToRemove.Path == entry.Path {
-				shouldRemove = false // do not remove binary if another command also references it
-			}
-			if shouldRemove {
-				absPath := filepath.Join(installDir, entryToRemove.Path)
-				if err := mgr.os.RemoveAll(absPath); err != nil {
-					return fmt.Errorf("unable to delete directory: %s due to %s", absPath, err)
-				}
-			}
-		}
-	}
-
 	// == Update dependencies.json ==
-	mgr.dependencyIndexHandler.SetEntries(
-		removeFromDependencyIndexList(
-			entriesToRemove,
-			mgr.dependencyIndexHandler.GetEntries(),
-		))
+	remainingEntries := removeFromDependencyIndexList(
+		entriesToRemove,
+		mgr.dependencyIndexHandler.GetEntries(),
+	)
+	mgr.dependencyIndexHandler.SetEntries(remainingEntries)
 	if err := mgr.dependencyIndexHandler.SaveDependencyIndex(); err != nil {
 		return err
 	}
 
-	return nil
+	// == Remove command from filesystem if no other command references it ==
+	return mgr.removeUnusedDirs(installDir, entriesToRemove, remainingEntries)
+}
+
+// LinkCommand registers the command directory in the installDir directory
+// under the alias, so the command can be used without packing and installing
+// it after each change.
+func (mgr *Manager) LinkCommand(alias string, commandDir string, installDir string) (*dependency.DependenciesIndexEntry, error) {
+	absPath, err := filepath.Abs(commandDir)
+	if err != nil {
+		return nil, err
+	}
+	commandConfig, err := mgr.verifyCommandConfig(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot link %s: %s", commandDir, err)
+	}
+	if err := mgr.os.MkdirAll(installDir, defaultDirPermissions); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %s due to %s", installDir, err)
+	}
+
+	entry := dependency.DependenciesIndexEntry{
+		Alias:   alias,
+		Name:    alias,
+		Version: commandConfig.Version,
+		Path:    absPath,
+		Kind:    dependency.LinkKind,
+	}
+	if err := mgr.addToIndex(entry, installDir); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// UnlinkCommand removes the linked command with the given alias from the
+// installDir directory. Linked directory itself is left intact.
+func (mgr *Manager) UnlinkCommand(alias string, installDir string) error {
+	entries, err := mgr.getIndexEntries(installDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Alias == alias && !entry.IsLink() {
+			return fmt.Errorf("%s is installed, not linked", alias)
+		}
+		if entry.Alias == alias {
+			return mgr.RemoveDependency(&dependency.Dependency{Alias: alias}, installDir)
+		}
+	}
+	return fmt.Errorf("%s is not linked", alias)
 }
 
 // GetInstalledCommands returns all the dependencies that are installed locally (both globally and within project scope).
@@ -294,9 +854,13 @@ func (mgr *Manager) GetInstalledCommands(paths context.Paths) []dependency.Depen
 			continue
 		}
 
-		// dependencies.json contains relative paths for commands, make them absolute
+		// dependencies.json contains relative paths for installed commands, make
+		// them absolute (paths of linked commands are absolute already)
 		for _, entry := range mgr.dependencyIndexHandler.GetEntries() {
-			entry.Path = filepath.Join(path, entry.Path)
+			if !entry.IsLink() {
+				entry.Path = filepath.Join(path, entry.Path)
+			}
+			entry.InstallDir = path
 			entries = append(entries, entry)
 		}
 	}
@@ -304,32 +868,171 @@ func (mgr *Manager) GetInstalledCommands(paths context.Paths) []dependency.Depen
 	return entries
 }
 
-func downloadFile(artifactoryClient *http.Client, url string, file io.Writer) (checksum string, err error) {
-	log.Verbosef("Downloading %s", url)
+// getRegistry returns initialized registry for the given url. It is safe to
+// call it from multiple goroutines, different registries are loaded concurrently.
+func (mgr *Manager) getRegistry(url string) (registry.Registry, error) {
+	unlock := mgr.lockKey("registry:" + url)
+	defer unlock()
+
+	mgr.registriesMutex.Lock()
+	depRegistry, ok := mgr.registries[url]
+	err, failed := mgr.registryErrors[url]
+	mgr.registriesMutex.Unlock()
+	if ok {
+		return depRegistry, nil
+	}
+	if failed {
+		return nil, err
+	}
 
-	resp, err := artifactoryClient.Get(url)
+	// mirror is tried first, then the registry itself and then fallbacks,
+	// error of the registry itself is reported if all of them fail
+	var urlErr error
+	for _, candidateUrl := range append(mgr.getMirroredURLs(url), mgr.FallbackRegistries...) {
+		depRegistry = mgr.newRegistry(candidateUrl)
+		if err = depRegistry.Update(); err == nil {
+			if candidateUrl != url {
+				log.Verbosef("Using registry %s instead of %s", auth.RedactURL(candidateUrl), auth.RedactURL(url))
+			}
+			break
+		}
+		if candidateUrl == url {
+			urlErr = err
+		}
+		log.Debugf("Cannot load registry %s: %s", auth.RedactURL(candidateUrl), err)
+	}
+	if err != nil && urlErr != nil {
+		err = urlErr
+	}
+
+	mgr.registriesMutex.Lock()
+	defer mgr.registriesMutex.Unlock()
 	if err != nil {
-		return "", err
+		if mgr.registryErrors == nil {
+			mgr.registryErrors = map[string]error{}
+		}
+		mgr.registryErrors[url] = err
+		return nil, err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-	if resp.StatusCode >= 300 {
-		return "", fmt.Errorf("artifactory responded: %s", resp.Status)
+	mgr.registries[url] = depRegistry
+
+	return depRegistry, nil
+}
+
+func (mgr *Manager) newRegistry(url string) registry.Registry {
+	if strings.HasPrefix(url, "file://") {
+		return registry.NewLocal(url)
 	}
+	return registry.NewRemote(url, registry.Options{
+		CacheDir: mgr.getRegistryCacheDir(),
+		Offline:  mgr.Offline,
+		Client:   mgr.httpDownloadClient,
+		Download: mgr.DownloadOptions,
+		Context:  mgr.getContext(),
+	})
+}
 
-	hash := sha256.New()
-	writer := io.MultiWriter(file, hash)
+// getMirroredURLs returns URLs under which resource with the given URL can be
+// found: URL of the mirror (if it is configured) followed by the URL itself.
+// Prefixes of mirrors are matched using scheme, host and path, so look-alike
+// hosts aren't mirrored.
+func (mgr *Manager) getMirroredURLs(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return []string{rawURL}
+	}
+	prefix := ""
+	for p := range mgr.Mirrors {
+		if auth.HasURLPrefix(u, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return []string{rawURL}
+	}
 
-	if term.IsTerminal(int(os.Stdout.Fd())) {
-		progress := progressbar.DefaultBytes(
-			resp.ContentLength, // value -1 indicates that the length is unknown
-			"Downloading",
-		)
-		writer = io.MultiWriter(writer, progress)
+	// part of the URL following the prefix is appended to the mirror
+	p, _ := url.Parse(prefix)
+	rest := strings.TrimPrefix(u.EscapedPath(), strings.TrimSuffix(p.EscapedPath(), "/"))
+	if strings.HasSuffix(p.EscapedPath(), "/") {
+		rest = strings.TrimPrefix(rest, "/")
 	}
+	if u.RawQuery != "" {
+		rest += "?" + u.RawQuery
+	}
+	return []string{mgr.Mirrors[prefix] + rest, rawURL}
+}
+
+// getRegistryCacheDir returns directory for storing indexes of remote registries.
+func (mgr *Manager) getRegistryCacheDir() string {
+	if mgr.Cache == nil {
+		return ""
+	}
+	return filepath.Join(mgr.Cache.Dir(), registriesCacheDirName)
+}
+
+// getContext returns context cancelling waiting for locks and downloads.
+func (mgr *Manager) getContext() stdcontext.Context {
+	if mgr.Context == nil {
+		return stdcontext.Background()
+	}
+	return mgr.Context
+}
+
+// lockKey prevents concurrent operations on the same resource (e.g. a path)
+// within the manager. Returned function releases the lock.
+func (mgr *Manager) lockKey(key string) func() {
+	mutex, _ := mgr.keyMutexes.LoadOrStore(key, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
 
-	if _, err = io.Copy(writer, resp.Body); err != nil {
+// getIndexEntries returns entries of dependencies.json in the installDir directory.
+func (mgr *Manager) getIndexEntries(installDir string) ([]dependency.DependenciesIndexEntry, error) {
+	mgr.indexMutex.Lock()
+	defer mgr.indexMutex.Unlock()
+
+	if err := mgr.dependencyIndexHandler.LoadDependencyIndex(filepath.Join(installDir, indexFileName)); err != nil {
+		return nil, err
+	}
+	return append([]dependency.DependenciesIndexEntry{}, mgr.dependencyIndexHandler.GetEntries()...), nil
+}
+
+// findRecorded returns entry describing the same artifact as the registry
+// entry, if its checksum is known.
+func findRecorded(entries []dependency.DependenciesIndexEntry, dep dependency.Dependency, registryEntry registry.Entry) *dependency.DependenciesIndexEntry {
+	for _, entry := range entries {
+		if entry.Name == dep.Name &&
+			entry.Registry == dep.Registry &&
+			entry.Version == registryEntry.Version &&
+			entry.OS == registryEntry.OS &&
+			entry.Arch == registryEntry.Arch &&
+			entry.Checksum != "" {
+			return &entry
+		}
+	}
+	return nil
+}
+
+// downloadFile downloads content of the url to the file and returns its
+// checksum.
+func (mgr *Manager) downloadFile(url string, file afero.File) (checksum string, err error) {
+	log.Verbosef("Downloading %s", auth.RedactURL(url))
+
+	var track func(contentLength int64) io.Writer
+	if mgr.progress != nil {
+		track = mgr.progress.track
+	}
+
+	if err := download.Download(mgr.getContext(), mgr.httpDownloadClient, url, file, mgr.DownloadOptions, track); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(hash, file); err != nil {
 		return "", err
 	}
 
@@ -1,20 +1,33 @@
 package manager
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 
+	"github.com/g2a-com/klio/internal/cache"
 	"github.com/g2a-com/klio/internal/context"
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/registry"
 	"github.com/g2a-com/klio/internal/lock"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/blake2b"
 )
 
 const (
@@ -52,7 +65,7 @@ type managerTestingSuite struct {
 	// Place to install your dependency
 	InstallDir string
 	// Function providing install lock
-	InstallLock func(string) (lock.Lock, error)
+	InstallLock func(string, lock.Options) (lock.Lock, error)
 
 	// Desired list of versions available locally
 	ExpectedDepsInstalledLocally []dependency.DependenciesIndexEntry
@@ -199,7 +212,8 @@ func TestInstallMinorUpgradeWithAcquireLockFailing(t *testing.T) {
 	var expectedListOfInstalledCommands []dependency.DependenciesIndexEntry
 	for _, e := range localTestDependencyIndexEntries {
 		expectedListOfInstalledCommands = append(expectedListOfInstalledCommands, dependency.DependenciesIndexEntry{
-			Path: filepath.Join(validProjectInstallPath, e),
+			Path:       filepath.Join(validProjectInstallPath, e),
+			InstallDir: validProjectInstallPath,
 		})
 	}
 
@@ -253,7 +267,8 @@ func TestInstallMinorUpgradeInCanonScenario(t *testing.T) {
 	var expectedListOfInstalledCommands []dependency.DependenciesIndexEntry
 	for _, e := range localTestDependencyIndexEntries {
 		expectedListOfInstalledCommands = append(expectedListOfInstalledCommands, dependency.DependenciesIndexEntry{
-			Path: filepath.Join(validProjectInstallPath, e),
+			Path:       filepath.Join(validProjectInstallPath, e),
+			InstallDir: validProjectInstallPath,
 		})
 	}
 
@@ -306,7 +321,8 @@ func TestInstallMajorUpdateWithDefaultRegistryFallbackOnDependency(t *testing.T)
 	var expectedListOfInstalledCommands []dependency.DependenciesIndexEntry
 	for _, e := range localTestDependencyIndexEntries {
 		expectedListOfInstalledCommands = append(expectedListOfInstalledCommands, dependency.DependenciesIndexEntry{
-			Path: filepath.Join(validProjectInstallPath, e),
+			Path:       filepath.Join(validProjectInstallPath, e),
+			InstallDir: validProjectInstallPath,
 		})
 	}
 
@@ -359,7 +375,8 @@ func TestInstallMajorUpdateWithoutDefaultRegistryFallback(t *testing.T) {
 	var expectedListOfInstalledCommands []dependency.DependenciesIndexEntry
 	for _, e := range localTestDependencyIndexEntries {
 		expectedListOfInstalledCommands = append(expectedListOfInstalledCommands, dependency.DependenciesIndexEntry{
-			Path: filepath.Join(validProjectInstallPath, e),
+			Path:       filepath.Join(validProjectInstallPath, e),
+			InstallDir: validProjectInstallPath,
 		})
 	}
 
@@ -383,7 +400,7 @@ func TestInstallMajorUpdateWithoutDefaultRegistryFallback(t *testing.T) {
 			Alias:    dependencyName,
 		},
 		CheckForUpdatesShouldFailWith: errors.New("registry url not set"),
-		CommandInstallShouldFailWith:  &CantFindExactVersionMatchError{dependencyName, "2.12.1", ""},
+		CommandInstallShouldFailWith:  errors.New("registry url not set"),
 	}
 
 	suite.Run(t, &mts)
@@ -414,7 +431,8 @@ func TestInstallMajorUpdateWithNonExistentHttpRegistry(t *testing.T) {
 	var expectedListOfInstalledCommands []dependency.DependenciesIndexEntry
 	for _, e := range localTestDependencyIndexEntries {
 		expectedListOfInstalledCommands = append(expectedListOfInstalledCommands, dependency.DependenciesIndexEntry{
-			Path: filepath.Join(validProjectInstallPath, e),
+			Path:       filepath.Join(validProjectInstallPath, e),
+			InstallDir: validProjectInstallPath,
 		})
 	}
 
@@ -438,8 +456,710 @@ func TestInstallMajorUpdateWithNonExistentHttpRegistry(t *testing.T) {
 			Alias:    dependencyName,
 		},
 		CheckForUpdatesShouldFailWith: errors.New("no such host"),
-		CommandInstallShouldFailWith:  &CantFindExactVersionMatchError{dependencyName, "2.12.1", "http://fake.registry.io"},
+		CommandInstallShouldFailWith:  errors.New("no such host"),
 	}
 
 	suite.Run(t, &mts)
 }
+
+func TestConcurrentInstallDependency(t *testing.T) {
+	remoteHttpRegistry := httptest.NewServer(&testHandler{})
+	defer remoteHttpRegistry.Close()
+
+	singleEntry := registry.Entry{
+		Name:    dependencyName,
+		Version: "2.12.1",
+		URL:     fmt.Sprintf("%s/%s/%s.tar.gz", remoteHttpRegistry.URL, "registry/commands", dependencyName),
+	}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	mgr, indexHandler := newTestManager(t)
+	mgr.registries[remoteHttpRegistry.URL] = r
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dep := dependency.Dependency{Name: dependencyName, Registry: remoteHttpRegistry.URL, Alias: fmt.Sprintf("alias%d", i), Version: "2.12.1"}
+			entry, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+			assert.NoError(t, err)
+			if assert.NotNil(t, entry) {
+				assert.Equal(t, dep.Alias, entry.Alias)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	indexHandler.AssertNumberOfCalls(t, "SaveDependencyIndex", 8)
+}
+
+func TestInstallDependencyFromCache(t *testing.T) {
+	archive, err := os.ReadFile(fmt.Sprintf("%s.tar.gz", dependencyName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(archive))
+
+	fs := getMockFs()
+	archiveCache := cache.New(fs, "/cache")
+	if err := archiveCache.Add(checksum, bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+
+	// registry server is not available, so the archive has to be taken from the cache
+	remoteHttpRegistry := httptest.NewServer(&testHandler{})
+	remoteHttpRegistry.Close()
+
+	singleEntry := registry.Entry{
+		Name:     dependencyName,
+		Version:  "2.12.1",
+		URL:      fmt.Sprintf("%s/%s/%s.tar.gz", remoteHttpRegistry.URL, "registry/commands", dependencyName),
+		Checksum: checksum,
+	}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	mgr, _ := newTestManager(t)
+	mgr.Cache = archiveCache
+	mgr.registries[remoteHttpRegistry.URL] = r
+	mgr.os = fs
+
+	dep := dependency.Dependency{Name: dependencyName, Registry: remoteHttpRegistry.URL, Alias: dependencyName, Version: "2.12.1"}
+	entry, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, checksum, entry.Checksum)
+	}
+}
+
+func TestInstallDependencySignature(t *testing.T) {
+	archive, err := os.ReadFile(fmt.Sprintf("%s.tar.gz", dependencyName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generate a minisign key pair and signatures
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	keyID := "testkey1"
+	trustedKey := base64.StdEncoding.EncodeToString(append([]byte("Ed"+keyID), publicKey...))
+	sign := func(message []byte) string {
+		hash := blake2b.Sum512(message)
+		return base64.StdEncoding.EncodeToString(append([]byte("ED"+keyID), ed25519.Sign(privateKey, hash[:])...))
+	}
+
+	remoteHttpRegistry := httptest.NewServer(&testHandler{})
+	defer remoteHttpRegistry.Close()
+
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   error
+	}{
+		{"Valid", sign(archive), nil},
+		{"Missing", "", &UnsignedArchiveError{}},
+		{"Invalid", sign([]byte("something else")), &InvalidSignatureError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			singleEntry := registry.Entry{
+				Name:      dependencyName,
+				Version:   "2.12.1",
+				URL:       fmt.Sprintf("%s/%s/%s.tar.gz", remoteHttpRegistry.URL, "registry/commands", dependencyName),
+				Signature: tt.signature,
+			}
+			r := new(mockRegistry)
+			r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+			mgr, _ := newTestManager(t)
+			mgr.registries[remoteHttpRegistry.URL] = r
+			mgr.AddTrustedKeys(remoteHttpRegistry.URL, []string{trustedKey})
+
+			dep := dependency.Dependency{Name: dependencyName, Registry: remoteHttpRegistry.URL, Alias: dependencyName, Version: "2.12.1"}
+			_, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInstallDependencyChecksumPolicies(t *testing.T) {
+	remoteHttpRegistry := httptest.NewServer(&testHandler{})
+	defer remoteHttpRegistry.Close()
+
+	recorded := dependency.DependenciesIndexEntry{
+		Alias:    "other",
+		Name:     dependencyName,
+		Registry: remoteHttpRegistry.URL,
+		Version:  "2.12.1",
+		Checksum: "sha256-0000000000000000000000000000000000000000000000000000000000000000",
+	}
+
+	tests := []struct {
+		name             string
+		requireChecksums bool
+		trustOnFirstUse  bool
+		wantErr          error
+	}{
+		{"NoPolicies", false, false, nil},
+		{"RequireChecksums", true, false, &MissingChecksumError{}},
+		{"TrustOnFirstUse", false, true, &ChecksumChangedError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			singleEntry := registry.Entry{
+				Name:    dependencyName,
+				Version: "2.12.1",
+				URL:     fmt.Sprintf("%s/%s/%s.tar.gz", remoteHttpRegistry.URL, "registry/commands", dependencyName),
+			}
+			r := new(mockRegistry)
+			r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+			mgr, _ := newTestManager(t, recorded)
+			mgr.RequireChecksums = tt.requireChecksums
+			mgr.TrustOnFirstUse = tt.trustOnFirstUse
+			mgr.registries[remoteHttpRegistry.URL] = r
+
+			dep := dependency.Dependency{Name: dependencyName, Registry: remoteHttpRegistry.URL, Alias: dependencyName, Version: "2.12.1"}
+			_, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInstallDependencyTrustedChecksums(t *testing.T) {
+	archive, err := os.ReadFile(fmt.Sprintf("%s.tar.gz", dependencyName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(archive))
+	swapped := false
+	remoteHttpRegistry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if swapped {
+			_, _ = w.Write([]byte("swapped archive"))
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer remoteHttpRegistry.Close()
+
+	singleEntry := registry.Entry{
+		Name:    dependencyName,
+		Version: "2.12.1",
+		URL:     fmt.Sprintf("%s/%s/%s.tar.gz", remoteHttpRegistry.URL, "registry/commands", dependencyName),
+	}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	// index never lists the installed version, as if the command had been
+	// upgraded in the meantime
+	mgr, _ := newTestManager(t)
+	archiveCache := cache.New(mgr.os, "/cache")
+	mgr.TrustOnFirstUse = true
+	mgr.Cache = archiveCache
+	mgr.registries[remoteHttpRegistry.URL] = r
+
+	dep := dependency.Dependency{Name: dependencyName, Registry: remoteHttpRegistry.URL, Alias: dependencyName, Version: "2.12.1"}
+	if _, err := mgr.InstallDependency(&dep, validProjectInstallPath); err != nil {
+		t.Fatalf("InstallDependency() error = %v", err)
+	}
+
+	// downgrade to the same version downloads it again
+	swapped = true
+	_ = archiveCache.Remove(checksum)
+	_, err = mgr.InstallDependency(&dep, validProjectInstallPath)
+	assert.IsType(t, &ChecksumChangedError{}, err)
+}
+
+func TestInstallBinaryDependency(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho hello\n")
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(binary))
+
+	fs := getMockFs()
+	archiveCache := cache.New(fs, "/cache")
+	if err := archiveCache.Add(checksum, bytes.NewReader(binary)); err != nil {
+		t.Fatal(err)
+	}
+
+	singleEntry := registry.Entry{
+		Name:        dependencyName,
+		Version:     "2.12.1",
+		URL:         fmt.Sprintf("https://example.com/%s/%s?os=linux", "registry/commands", dependencyName),
+		Checksum:    checksum,
+		Annotations: map[string]string{"description": "Does something"},
+	}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	mgr, _ := newTestManager(t)
+	mgr.Cache = archiveCache
+	mgr.registries["https://example.com/"] = r
+	mgr.os = fs
+
+	dep := dependency.Dependency{Name: dependencyName, Registry: "https://example.com/", Alias: dependencyName, Version: "2.12.1"}
+	entry, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	installedBinary, err := afero.ReadFile(fs, filepath.Join(validProjectInstallPath, entry.Path, dependencyName))
+	if assert.NoError(t, err) {
+		assert.Equal(t, binary, installedBinary)
+	}
+	commandConfig, err := afero.ReadFile(fs, filepath.Join(validProjectInstallPath, entry.Path, commandConfigFileName))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "apiVersion: klio/v1\nkind: Command\nbinPath: dosomething\ndescription: Does something\nversion: 2.12.1\n", string(commandConfig))
+	}
+}
+
+func TestInstallDependencyRemovesUnusedDirectories(t *testing.T) {
+	archive, err := os.ReadFile(fmt.Sprintf("%s.tar.gz", dependencyName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(archive))
+
+	fs := getMockFs()
+	archiveCache := cache.New(fs, "/cache")
+	if err := archiveCache.Add(checksum, bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+	sharedDir := filepath.Join(dependenciesDirectoryName, "sha256-shared")
+	uniqueDir := filepath.Join(dependenciesDirectoryName, "sha256-unique")
+	for _, dir := range []string{sharedDir, uniqueDir} {
+		_ = fs.MkdirAll(filepath.Join(validProjectInstallPath, dir), defaultDirPermissions)
+	}
+
+	singleEntry := registry.Entry{Name: dependencyName, Version: "2.12.1", URL: "https://example.com/dosomething.tar.gz", Checksum: checksum}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	entries := []dependency.DependenciesIndexEntry{
+		{Alias: "a", Name: dependencyName, Path: sharedDir},
+		{Alias: "b", Name: dependencyName, Path: sharedDir},
+		{Alias: "c", Name: dependencyName, Path: uniqueDir},
+	}
+	mgr, _ := newTestManager(t, entries...)
+	mgr.Cache = archiveCache
+	mgr.registries["https://example.com/"] = r
+	mgr.os = fs
+
+	for _, alias := range []string{"a", "c"} {
+		dep := dependency.Dependency{Name: dependencyName, Registry: "https://example.com/", Alias: alias, Version: "2.12.1"}
+		if _, err := mgr.InstallDependency(&dep, validProjectInstallPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exists, _ := afero.DirExists(fs, filepath.Join(validProjectInstallPath, sharedDir))
+	assert.True(t, exists, "directory used by another alias should be kept")
+	exists, _ = afero.DirExists(fs, filepath.Join(validProjectInstallPath, uniqueDir))
+	assert.False(t, exists, "directory which is not used anymore should be removed")
+	files, _ := afero.ReadDir(fs, filepath.Join(validProjectInstallPath, dependenciesDirectoryName))
+	assert.Len(t, files, 2, "staging directories should be removed")
+}
+
+// renameHookFs calls beforeRename before renaming files, so tests can
+// simulate other processes modifying the file system.
+type renameHookFs struct {
+	afero.Fs
+	beforeRename func(oldname, newname string) error
+}
+
+func (fs *renameHookFs) Rename(oldname, newname string) error {
+	if err := fs.beforeRename(oldname, newname); err != nil {
+		return err
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func TestInstallDependencyReusesExistingDirectory(t *testing.T) {
+	archive, err := os.ReadFile(fmt.Sprintf("%s.tar.gz", dependencyName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(archive))
+	outputDir := filepath.Join(validProjectInstallPath, dependenciesDirectoryName, checksum)
+	// copy of the command, as if it was installed by another process
+	writeInstalledCommand := func(fs afero.Fs) {
+		_ = afero.WriteFile(fs, filepath.Join(outputDir, commandConfigFileName), []byte("kind: Command\nbinPath: cmd\n"), 0o644)
+		_ = afero.WriteFile(fs, filepath.Join(outputDir, "cmd"), []byte("#!/bin/sh"), 0o755)
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(fs afero.Fs) afero.Fs
+	}{
+		{
+			name: "Existing",
+			prepare: func(fs afero.Fs) afero.Fs {
+				writeInstalledCommand(fs)
+				return fs
+			},
+		},
+		{
+			name: "CreatedConcurrently",
+			prepare: func(fs afero.Fs) afero.Fs {
+				return &renameHookFs{Fs: fs, beforeRename: func(_, newname string) error {
+					if newname != outputDir {
+						return nil
+					}
+					writeInstalledCommand(fs)
+					return &os.LinkError{Op: "rename", New: newname, Err: errors.New("directory not empty")}
+				}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memFs := getMockFs()
+			archiveCache := cache.New(memFs, "/cache")
+			if err := archiveCache.Add(checksum, bytes.NewReader(archive)); err != nil {
+				t.Fatal(err)
+			}
+			singleEntry := registry.Entry{Name: dependencyName, Version: "2.12.1", URL: "https://example.com/dosomething.tar.gz", Checksum: checksum}
+			r := new(mockRegistry)
+			r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+			mgr, _ := newTestManager(t)
+			mgr.Cache = archiveCache
+			mgr.registries["https://example.com/"] = r
+			mgr.os = tt.prepare(memFs)
+
+			dep := dependency.Dependency{Name: dependencyName, Registry: "https://example.com/", Alias: dependencyName, Version: "2.12.1"}
+			if _, err := mgr.InstallDependency(&dep, validProjectInstallPath); !assert.NoError(t, err) {
+				return
+			}
+			exists, _ := afero.Exists(memFs, filepath.Join(outputDir, "cmd"))
+			assert.True(t, exists, "existing directory should be reused")
+			files, _ := afero.ReadDir(memFs, filepath.Dir(outputDir))
+			assert.Len(t, files, 1, "staging directories should be removed")
+		})
+	}
+}
+
+func TestRemoveUnusedDirsKeepsPendingDirectories(t *testing.T) {
+	fs := getMockFs()
+	relPath := filepath.Join(dependenciesDirectoryName, "sha256-pending")
+	absPath := filepath.Join(validProjectInstallPath, relPath)
+	_ = fs.MkdirAll(absPath, defaultDirPermissions)
+	mgr := &Manager{os: fs}
+	removed := []dependency.DependenciesIndexEntry{{Alias: "old", Path: relPath}}
+
+	// another alias has just been installed into the same directory
+	mgr.setPending(absPath, 1)
+	assert.NoError(t, mgr.removeUnusedDirs(validProjectInstallPath, removed, nil))
+	exists, _ := afero.DirExists(fs, absPath)
+	assert.True(t, exists, "directory of a pending installation should be kept")
+
+	mgr.setPending(absPath, -1)
+	assert.NoError(t, mgr.removeUnusedDirs(validProjectInstallPath, removed, nil))
+	exists, _ = afero.DirExists(fs, absPath)
+	assert.False(t, exists, "unused directory should be removed")
+}
+
+func TestInstallInvalidCommand(t *testing.T) {
+	// archive without command.yaml
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	_ = tarWriter.WriteHeader(&tar.Header{Name: "somefile.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 3})
+	_, _ = tarWriter.Write([]byte("abc"))
+	_ = tarWriter.Close()
+	_ = gzipWriter.Close()
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(buf.Bytes()))
+
+	fs := getMockFs()
+	archiveCache := cache.New(fs, "/cache")
+	if err := archiveCache.Add(checksum, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	installedDir := filepath.Join(validProjectInstallPath, dependenciesDirectoryName, checksum)
+	_ = afero.WriteFile(fs, filepath.Join(installedDir, "command.yaml"), []byte("previous"), 0o644)
+
+	singleEntry := registry.Entry{Name: dependencyName, Version: "2.12.1", URL: "https://example.com/dosomething.tar.gz", Checksum: checksum}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	mgr := &Manager{
+		Cache:                  archiveCache,
+		registries:             map[string]registry.Registry{"https://example.com/": r},
+		os:                     fs,
+		dependencyIndexHandler: new(mockIndexHandler),
+		createLock:             newMockLock,
+	}
+
+	dep := dependency.Dependency{Name: dependencyName, Registry: "https://example.com/", Alias: dependencyName, Version: "2.12.1"}
+	_, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+	var invalidCommandErr *InvalidCommandError
+	assert.ErrorAs(t, err, &invalidCommandErr)
+
+	// previously installed files are left untouched
+	content, _ := afero.ReadFile(fs, filepath.Join(installedDir, "command.yaml"))
+	assert.Equal(t, "previous", string(content))
+	files, _ := afero.ReadDir(fs, filepath.Join(validProjectInstallPath, dependenciesDirectoryName))
+	assert.Len(t, files, 1, "staging directories should be removed")
+}
+
+func TestInstallDependencyFromMirrors(t *testing.T) {
+	// newRegistryServer returns server with registry index at /registry.yaml,
+	// which lists archive located on the archiveServer
+	newRegistryServer := func(archiveServer func() string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/registry.yaml" {
+				(&testHandler{}).ServeHTTP(w, r)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "entries:\n  - name: %s\n    version: 2.12.1\n    url: %s/registry/commands/%s.tar.gz\n", dependencyName, archiveServer(), dependencyName)
+		}))
+	}
+	unavailable := httptest.NewServer(&testHandler{})
+	unavailable.Close()
+	mirror := newRegistryServer(func() string { return unavailable.URL })
+	defer mirror.Close()
+	var fallbackURL string
+	fallback := newRegistryServer(func() string { return fallbackURL })
+	defer fallback.Close()
+	fallbackURL = fallback.URL
+
+	tests := []struct {
+		name     string
+		mirrors  map[string]string
+		fallback []string
+	}{
+		{name: "Mirror", mirrors: map[string]string{unavailable.URL + "/": mirror.URL + "/"}},
+		{name: "FallbackRegistry", fallback: []string{fallback.URL + "/registry.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, _ := newTestManager(t)
+			mgr.Mirrors = tt.mirrors
+			mgr.FallbackRegistries = tt.fallback
+
+			dep := dependency.Dependency{Name: dependencyName, Registry: unavailable.URL + "/registry.yaml", Version: "2.12.1"}
+			entry, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+			if assert.NoError(t, err) {
+				assert.Equal(t, unavailable.URL+"/registry.yaml", entry.Registry)
+			}
+		})
+	}
+}
+
+func TestGetMirroredURLs(t *testing.T) {
+	mgr := &Manager{Mirrors: map[string]string{
+		"https://reg.example.com":       "https://mirror.example.com/klio",
+		"https://reg.example.com/team/": "https://mirror.example.com/team/",
+	}}
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://reg.example.com/registry.yaml", []string{"https://mirror.example.com/klio/registry.yaml", "https://reg.example.com/registry.yaml"}},
+		{"https://reg.example.com/team/registry.yaml?v=1", []string{"https://mirror.example.com/team/registry.yaml?v=1", "https://reg.example.com/team/registry.yaml?v=1"}},
+		// look-alike hosts and other ports mustn't be mirrored
+		{"https://reg.example.com.evil.tld/registry.yaml", []string{"https://reg.example.com.evil.tld/registry.yaml"}},
+		{"https://reg.example.com:8443/registry.yaml", []string{"https://reg.example.com:8443/registry.yaml"}},
+		{"http://reg.example.com/registry.yaml", []string{"http://reg.example.com/registry.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.want, mgr.getMirroredURLs(tt.url))
+		})
+	}
+}
+
+func TestInstallDependencyFromCorruptedMirror(t *testing.T) {
+	archive, err := os.ReadFile(fmt.Sprintf("%s.tar.gz", dependencyName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("sha256-%x", sha256.Sum256(archive))
+
+	original := httptest.NewServer(&testHandler{})
+	defer original.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("corrupted"))
+	}))
+	defer mirror.Close()
+
+	singleEntry := registry.Entry{
+		Name:     dependencyName,
+		Version:  "2.12.1",
+		URL:      fmt.Sprintf("%s/%s/%s.tar.gz", original.URL, "registry/commands", dependencyName),
+		Checksum: checksum,
+	}
+	r := new(mockRegistry)
+	r.On("GetExactMatch", mock.Anything).Return(&singleEntry, nil)
+
+	mgr, _ := newTestManager(t)
+	mgr.Mirrors = map[string]string{original.URL + "/": mirror.URL + "/"}
+	mgr.registries[original.URL] = r
+
+	dep := dependency.Dependency{Name: dependencyName, Registry: original.URL, Alias: dependencyName, Version: "2.12.1"}
+	entry, err := mgr.InstallDependency(&dep, validProjectInstallPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, checksum, entry.Checksum)
+	}
+}
+
+func TestLinkCommand(t *testing.T) {
+	fs := getMockFs()
+	commandDir := "/work/hello"
+	_ = afero.WriteFile(fs, filepath.Join(commandDir, commandConfigFileName), []byte("apiVersion: klio/v1\nkind: Command\nbinPath: hello.sh\nversion: 1.0.0\n"), 0o644)
+	_ = afero.WriteFile(fs, filepath.Join(commandDir, "hello.sh"), []byte("#!/bin/sh"), 0o755)
+	installedDir := filepath.Join(dependenciesDirectoryName, "sha256-installed")
+	_ = fs.MkdirAll(filepath.Join(validProjectInstallPath, installedDir), defaultDirPermissions)
+
+	linkedEntry := dependency.DependenciesIndexEntry{Alias: "hello", Name: "hello", Version: "1.0.0", Path: commandDir, Kind: dependency.LinkKind}
+
+	indexHandler := new(mockIndexHandler)
+	indexHandler.On("LoadDependencyIndex", mock.Anything).Return(nil)
+	indexHandler.On("GetEntries").Return([]dependency.DependenciesIndexEntry{{Alias: "hello", Name: "hello", Path: installedDir}})
+	indexHandler.On("SetEntries", []dependency.DependenciesIndexEntry{linkedEntry})
+	indexHandler.On("SaveDependencyIndex").Return(nil)
+	mgr := &Manager{os: fs, dependencyIndexHandler: indexHandler, createLock: newMockLock}
+
+	entry, err := mgr.LinkCommand("hello", commandDir, validProjectInstallPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, linkedEntry, *entry)
+	}
+	indexHandler.AssertExpectations(t)
+	exists, _ := afero.DirExists(fs, filepath.Join(validProjectInstallPath, installedDir))
+	assert.False(t, exists, "directory of the replaced command should be removed")
+
+	indexHandler = new(mockIndexHandler)
+	indexHandler.On("LoadDependencyIndex", mock.Anything).Return(nil)
+	indexHandler.On("GetEntries").Return([]dependency.DependenciesIndexEntry{linkedEntry})
+	indexHandler.On("SetEntries", []dependency.DependenciesIndexEntry{})
+	indexHandler.On("SaveDependencyIndex").Return(nil)
+	mgr.dependencyIndexHandler = indexHandler
+
+	assert.Error(t, mgr.UnlinkCommand("other", validProjectInstallPath))
+	assert.NoError(t, mgr.UnlinkCommand("hello", validProjectInstallPath))
+	indexHandler.AssertExpectations(t)
+	exists, _ = afero.Exists(fs, filepath.Join(commandDir, "hello.sh"))
+	assert.True(t, exists, "linked directory should be left intact")
+}
+
+func TestLinkInvalidCommand(t *testing.T) {
+	fs := getMockFs()
+	_ = afero.WriteFile(fs, "/work/hello/command.yaml", []byte("kind: Command\nbinPath: missing.sh\n"), 0o644)
+	mgr := &Manager{os: fs, dependencyIndexHandler: new(mockIndexHandler), createLock: newMockLock}
+
+	_, err := mgr.LinkCommand("hello", "/work/hello", validProjectInstallPath)
+	assert.ErrorContains(t, err, "missing.sh")
+}
+
+func TestCheckInstallDir(t *testing.T) {
+	fs := getMockFs()
+	writeCommand := func(dir string, config string, mode os.FileMode) {
+		_ = afero.WriteFile(fs, filepath.Join(validProjectInstallPath, dir, commandConfigFileName), []byte(config), 0o644)
+		_ = afero.WriteFile(fs, filepath.Join(validProjectInstallPath, dir, "cmd"), []byte("#!/bin/sh"), mode)
+	}
+	writeCommand("dependencies/valid", "apiVersion: klio/v1\nkind: Command\nbinPath: cmd\n", 0o755)
+	writeCommand("dependencies/unsupported", "apiVersion: klio/v9\nkind: Command\nbinPath: cmd\n", 0o755)
+	writeCommand("dependencies/not-executable", "apiVersion: klio/v1\nkind: Command\nbinPath: cmd\n", 0o644)
+	writeCommand("dependencies/invalid", "kind: Command\n", 0o755)
+	writeCommand("dependencies/other-platform", "apiVersion: klio/v1\nkind: Command\nbinPath: cmd\n", 0o755)
+	writeCommand("dependencies/unused", "apiVersion: klio/v1\nkind: Command\nbinPath: cmd\n", 0o755)
+	_ = fs.MkdirAll(filepath.Join(validProjectInstallPath, "dependencies", stagingDirPrefix+"valid"), defaultDirPermissions)
+
+	indexEntries := []dependency.DependenciesIndexEntry{
+		{Alias: "valid", Path: "dependencies/valid"},
+		{Alias: "unsupported", Path: "dependencies/unsupported"},
+		{Alias: "not-executable", Path: "dependencies/not-executable"},
+		{Alias: "invalid", Path: "dependencies/invalid"},
+		{Alias: "other-platform", Path: "dependencies/other-platform", OS: "plan9", Arch: "mips"},
+		{Alias: "missing", Path: "dependencies/missing"},
+		{Alias: "linked", Path: "/work/missing", Kind: dependency.LinkKind},
+	}
+	mgr, _ := newTestManager(t, indexEntries...)
+	mgr.os = fs
+
+	entries, problems := mgr.CheckInstallDir(validProjectInstallPath)
+	assert.Len(t, entries, 7)
+	assert.Equal(t, filepath.Join(validProjectInstallPath, "dependencies/valid"), entries[0].Path)
+
+	found := map[string]ProblemKind{}
+	for _, problem := range problems {
+		if problem.Entry != nil {
+			found[problem.Entry.Alias] = problem.Kind
+		} else {
+			found[filepath.Base(problem.Path)] = problem.Kind
+		}
+	}
+	expected := map[string]ProblemKind{
+		"unsupported":              UnsupportedAPIVersionProblem,
+		"invalid":                  InvalidCommandProblem,
+		"other-platform":           PlatformMismatchProblem,
+		"missing":                  MissingDirectoryProblem,
+		"linked":                   MissingDirectoryProblem,
+		"unused":                   UnusedDirectoryProblem,
+		stagingDirPrefix + "valid": UnusedDirectoryProblem,
+	}
+	if runtime.GOOS != "windows" {
+		expected["not-executable"] = NotExecutableProblem
+	}
+	assert.Equal(t, expected, found)
+}
+
+func TestCheckInstallDirWithInvalidIndex(t *testing.T) {
+	indexHandler := new(mockIndexHandler)
+	indexHandler.On("LoadDependencyIndex", mock.Anything).Return(errors.New("invalid JSON"))
+	mgr := &Manager{os: getMockFs(), dependencyIndexHandler: indexHandler}
+
+	entries, problems := mgr.CheckInstallDir(validProjectInstallPath)
+	assert.Empty(t, entries)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, InvalidIndexProblem, problems[0].Kind)
+	}
+}
+
+func TestCheckRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/registry.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "entries:\n  - name: %s\n    version: 1.0.0\n", dependencyName)
+	}))
+	defer server.Close()
+	// mirrors and fallbacks mustn't hide unavailable registries
+	mgr := &Manager{
+		Mirrors:            map[string]string{server.URL + "/missing/": server.URL + "/"},
+		FallbackRegistries: []string{server.URL + "/registry.yaml"},
+		httpDownloadClient: http.DefaultClient,
+	}
+
+	assert.NoError(t, mgr.CheckRegistry(server.URL+"/registry.yaml"))
+	assert.Error(t, mgr.CheckRegistry(server.URL+"/missing/registry.yaml"))
+}
+
+func TestGetRegistryReturnsErrorToEveryCaller(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer fallback.Close()
+	mgr := &Manager{
+		FallbackRegistries: []string{fallback.URL + "/registry.yaml"},
+		registries:         map[string]registry.Registry{},
+		httpDownloadClient: http.DefaultClient,
+	}
+	dep := dependency.Dependency{Name: dependencyName, Registry: server.URL + "/registry.yaml", Version: "*"}
+
+	for i := 0; i < 2; i++ {
+		version, err := mgr.GetMatchingVersionFor(dep)
+		// error of the registry itself is reported, not the one of the fallback
+		assert.ErrorContains(t, err, "404", "call %d", i+1)
+		assert.Empty(t, version, "call %d", i+1)
+	}
+}
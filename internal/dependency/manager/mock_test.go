@@ -1,9 +1,11 @@
 package manager
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"testing"
 
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/registry"
@@ -58,6 +60,11 @@ func (r *mockRegistry) GetHighestNonBreaking(dep dependency.Dependency) (*regist
 	return args.Get(0).(*registry.Entry), args.Error(1)
 }
 
+func (r *mockRegistry) GetEntries() []registry.Entry {
+	args := r.Called()
+	return args.Get(0).([]registry.Entry)
+}
+
 func (r *mockRegistry) GetExactMatch(dep dependency.Dependency) (*registry.Entry, error) {
 	args := r.Called(dep)
 	return args.Get(0).(*registry.Entry), args.Error(1)
@@ -103,21 +110,21 @@ type mockLock struct {
 	FailingAcquire bool
 }
 
-func newMockLock(_ string) (lock.Lock, error) {
+func newMockLock(_ string, _ lock.Options) (lock.Lock, error) {
 	ml := new(mockLock)
 	ml.On("Acquire")
 	ml.On("Release")
 	return ml, nil
 }
 
-func newMockLockFailingToAcquire(_ string) (lock.Lock, error) {
+func newMockLockFailingToAcquire(_ string, _ lock.Options) (lock.Lock, error) {
 	ml := &mockLock{FailingAcquire: true}
 	ml.On("Acquire")
 	ml.On("Release")
 	return ml, nil
 }
 
-func (ml *mockLock) Acquire() error {
+func (ml *mockLock) Acquire(_ context.Context) error {
 	_ = ml.Called()
 	if ml.FailingAcquire {
 		return &AcquireLockError{}
@@ -146,3 +153,23 @@ func getMockFs() afero.Fs {
 
 	return fs
 }
+
+// newTestManager returns manager using an in-memory file system, mock locks
+// and an index handler mock listing the given entries.
+func newTestManager(t *testing.T, entries ...dependency.DependenciesIndexEntry) (*Manager, *mockIndexHandler) {
+	t.Helper()
+	indexHandler := new(mockIndexHandler)
+	indexHandler.On("LoadDependencyIndex", mock.Anything).Return(nil)
+	indexHandler.On("GetEntries").Return(append([]dependency.DependenciesIndexEntry{}, entries...))
+	indexHandler.On("SetEntries", mock.Anything)
+	indexHandler.On("SaveDependencyIndex").Return(nil)
+
+	mgr := &Manager{
+		registries:             map[string]registry.Registry{},
+		os:                     getMockFs(),
+		httpDownloadClient:     http.DefaultClient,
+		dependencyIndexHandler: indexHandler,
+		createLock:             newMockLock,
+	}
+	return mgr, indexHandler
+}
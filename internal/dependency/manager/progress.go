@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
+)
+
+// progress aggregates progress of concurrent downloads and displays it as
+// a single progress bar.
+type progress struct {
+	mutex         sync.Mutex
+	bar           *progressbar.ProgressBar
+	total         int
+	finished      int
+	unknownLength bool
+}
+
+// StartProgress displays a single progress bar for the given number of
+// downloads made until StopProgress is called. Nothing is displayed if stdout
+// is not a terminal.
+func (mgr *Manager) StartProgress(total int) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	// Max value starts with an additional byte, so the bar isn't completed
+	// before all downloads are started.
+	mgr.progress = &progress{
+		bar:   progressbar.DefaultBytes(1, describeProgress(0, total)),
+		total: total,
+	}
+}
+
+// StopProgress completes the progress bar displayed by StartProgress.
+func (mgr *Manager) StopProgress() {
+	if mgr.progress == nil {
+		return
+	}
+	mgr.progress.finish()
+	mgr.progress = nil
+}
+
+// track registers a new download and returns writer for its content.
+func (p *progress) track(contentLength int64) io.Writer {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch {
+	case contentLength < 0:
+		p.unknownLength = true
+		p.bar.ChangeMax64(-1)
+	case !p.unknownLength:
+		p.bar.AddMax64(contentLength)
+	}
+
+	return p.bar
+}
+
+// done marks a download as finished.
+func (p *progress) done() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.finished++
+	p.bar.Describe(describeProgress(p.finished, p.total))
+}
+
+func (p *progress) finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.unknownLength {
+		_ = p.bar.Exit()
+	} else {
+		_ = p.bar.Finish()
+	}
+}
+
+func describeProgress(finished int, total int) string {
+	return fmt.Sprintf("Downloading (%d/%d)", finished, total)
+}
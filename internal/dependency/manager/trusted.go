@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/dependency/registry"
+)
+
+// trustedChecksumsFileName is a file in the cache directory recording
+// checksums of archives downloaded for the first time. It is append-only, so
+// checksums are remembered after commands are upgraded or removed.
+const trustedChecksumsFileName = "trusted-checksums"
+
+// getTrustedChecksumsFile returns path of the file with trusted checksums, or
+// an empty string if there is no cache.
+func (mgr *Manager) getTrustedChecksumsFile() string {
+	if mgr.Cache == nil {
+		return ""
+	}
+	return filepath.Join(mgr.Cache.Dir(), trustedChecksumsFileName)
+}
+
+// getTrustedChecksumKey identifies the artifact described by the registry
+// entry. Fields are separated with tabs, which can't be a part of any of them.
+func getTrustedChecksumKey(registryURL string, registryEntry registry.Entry) string {
+	return strings.Join([]string{registryURL, registryEntry.Name, registryEntry.Version, registryEntry.OS, registryEntry.Arch}, "\t")
+}
+
+// findTrustedChecksum returns checksum recorded for the key, or an empty
+// string if the artifact hasn't been downloaded yet.
+func (mgr *Manager) findTrustedChecksum(key string) (string, error) {
+	path := mgr.getTrustedChecksumsFile()
+	if path == "" {
+		return "", nil
+	}
+	file, err := mgr.os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("cannot read trusted checksums: %s", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// the first record wins, later ones could be added by concurrent processes
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if recordKey, checksum, ok := cutLast(scanner.Text(), "\t"); ok && recordKey == key {
+			return checksum, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("cannot read trusted checksums: %s", err)
+	}
+	return "", nil
+}
+
+// addTrustedChecksum appends record of the checksum to the file.
+func (mgr *Manager) addTrustedChecksum(key string, checksum string) error {
+	path := mgr.getTrustedChecksumsFile()
+	if path == "" {
+		return nil
+	}
+	if err := mgr.os.MkdirAll(filepath.Dir(path), defaultDirPermissions); err != nil {
+		return err
+	}
+	file, err := mgr.os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// single write, so records of concurrent processes aren't interleaved
+	_, err = file.Write([]byte(key + "\t" + checksum + "\n"))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func cutLast(s string, sep string) (before string, after string, found bool) {
+	if idx := strings.LastIndex(s, sep); idx >= 0 {
+		return s[:idx], s[idx+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,362 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/g2a-com/klio/internal/archive"
+	"github.com/g2a-com/klio/internal/cmd"
+	"github.com/g2a-com/klio/internal/log"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	commandConfigFileName = "command.yaml"
+	descriptionAnnotation = "description"
+)
+
+var (
+	separatorRegexp = regexp.MustCompile(`[-_]`)
+	// versionRegexp matches the beginning of a version, which is parsed
+	// using semver
+	versionRegexp = regexp.MustCompile(`^v?[0-9]+\.[0-9]+`)
+)
+
+// archiveSuffixes are removed from file names before looking for the name,
+// version, OS and architecture of a command.
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.zst", ".tzst", ".tar", ".zip"}
+
+var operatingSystems = map[string]string{
+	"linux":   "linux",
+	"darwin":  "darwin",
+	"macos":   "darwin",
+	"osx":     "darwin",
+	"windows": "windows",
+	"win":     "windows",
+	"freebsd": "freebsd",
+	"openbsd": "openbsd",
+	"netbsd":  "netbsd",
+}
+
+var architectures = map[string]string{
+	"amd64":   "amd64",
+	"x64":     "amd64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"386":     "386",
+	"i386":    "386",
+	"x86":     "386",
+	"arm":     "arm",
+	"armv7":   "arm",
+}
+
+// Build adds entries describing archives found in dir (and its
+// subdirectories) to the index. Each archive has to contain command.yaml,
+// command name is taken from the file name unless name isn't empty. Entries
+// already present in the index are updated, all other entries and annotations
+// are kept intact.
+func Build(fs afero.Fs, index *Index, dir string, baseURL string, name string) error {
+	if index.APIVersion == "" {
+		index.APIVersion = "klio/v1"
+	}
+	if index.Kind == "" {
+		index.Kind = "Registry"
+	}
+
+	return afero.Walk(fs, dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		fileURL, err := url.JoinPath(baseURL, strings.Split(filepath.ToSlash(relPath), "/")...)
+		if err != nil {
+			return err
+		}
+
+		entry, err := buildEntry(fs, filePath, fileURL, name)
+		if err != nil {
+			return fmt.Errorf("cannot add %s to the registry: %s", relPath, err)
+		}
+		if entry != nil {
+			mergeEntry(index, *entry)
+		}
+		return nil
+	})
+}
+
+// buildEntry returns registry entry describing the archive, or nil if the
+// file isn't an archive. Name found in the file name is replaced with the
+// given one, if it isn't empty.
+func buildEntry(fs afero.Fs, filePath string, fileURL string, name string) (*Entry, error) {
+	file, err := fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	format, err := archive.DetectFormat("", filePath, file)
+	if err != nil || format == archive.FormatBinary {
+		log.Verbosef("Skipping %s, it isn't an archive", filePath)
+		return nil, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+
+	tempDir, err := afero.TempDir(fs, "", "klio-registry-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fs.RemoveAll(tempDir) }()
+	if err := archive.Extract(file, format, fs, tempDir, archive.DefaultLimits); err != nil {
+		return nil, err
+	}
+	commandConfig, err := readCommandConfig(fs, tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName, version, goos, goarch := parseFileName(filepath.Base(filePath))
+	if name == "" {
+		name = fileName
+	}
+	if name == "" {
+		return nil, fmt.Errorf("cannot find command name in the file name")
+	}
+	if commandConfig.Version != "" {
+		version = strings.TrimPrefix(commandConfig.Version, "v")
+	}
+	if version == "" {
+		return nil, fmt.Errorf("cannot find version in %s nor in the file name", commandConfigFileName)
+	}
+	if goos == "" && goarch == "" {
+		goos, goarch = DetectPlatform(fs, filepath.Join(tempDir, commandConfig.BinPath))
+	}
+
+	entry := &Entry{
+		Name:     name,
+		Version:  version,
+		OS:       goos,
+		Arch:     goarch,
+		URL:      fileURL,
+		Checksum: fmt.Sprintf("sha256-%x", hash.Sum(nil)),
+	}
+	if commandConfig.Description != "" {
+		entry.Annotations = map[string]string{descriptionAnnotation: commandConfig.Description}
+	}
+	log.Verbosef("Found %s@%s (%s) in %s", entry.Name, entry.Version, platformName(*entry), filePath)
+
+	return entry, nil
+}
+
+// readCommandConfig reads command.yaml from the extracted archive.
+func readCommandConfig(fs afero.Fs, dir string) (*cmd.Config, error) {
+	data, err := afero.ReadFile(fs, filepath.Join(dir, commandConfigFileName))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s", commandConfigFileName, err)
+	}
+	commandConfig := &cmd.Config{}
+	if err := yaml.Unmarshal(data, commandConfig); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %s", commandConfigFileName, err)
+	}
+	if commandConfig.Kind != "Command" {
+		return nil, fmt.Errorf(`%s has invalid kind "%s"`, commandConfigFileName, commandConfig.Kind)
+	}
+	if commandConfig.BinPath == "" {
+		return nil, fmt.Errorf("%s doesn't specify binPath", commandConfigFileName)
+	}
+	return commandConfig, nil
+}
+
+// parseFileName splits file names like "hello-1.2.0-linux-amd64.tar.gz" into
+// a command name, version, OS and architecture. Name is the part of the file
+// name preceding the first recognized part, the rest may be empty.
+func parseFileName(fileName string) (name string, version string, goos string, goarch string) {
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(strings.ToLower(fileName), suffix) {
+			fileName = fileName[:len(fileName)-len(suffix)]
+			break
+		}
+	}
+
+	// bounds of parts separated by "-" and "_"
+	var bounds [][2]int
+	start := 0
+	for _, loc := range separatorRegexp.FindAllStringIndex(fileName, -1) {
+		bounds = append(bounds, [2]int{start, loc[0]})
+		start = loc[1]
+	}
+	bounds = append(bounds, [2]int{start, len(fileName)})
+	part := func(i int) string {
+		return strings.ToLower(fileName[bounds[i][0]:bounds[i][1]])
+	}
+	// platformPart returns OS or architecture starting at the i-th part and
+	// the number of parts it consists of ("x86_64" consists of two)
+	platformPart := func(i int) (string, string, int) {
+		if i+1 < len(bounds) && part(i) == "x86" && part(i+1) == "64" {
+			return "", "amd64", 2
+		}
+		return operatingSystems[part(i)], architectures[part(i)], 1
+	}
+
+	// parseVersion returns version starting at the i-th part and the number
+	// of parts it consists of. Prerelease versions (e.g. "1.2.0-rc.1") may
+	// consist of many parts, the longest valid one is used.
+	parseVersion := func(i int) (string, int) {
+		if !versionRegexp.MatchString(part(i)) {
+			return "", 0
+		}
+		last := i
+		for last+1 < len(bounds) {
+			if partOS, partArch, _ := platformPart(last + 1); partOS != "" || partArch != "" {
+				break
+			}
+			last++
+		}
+		for ; last >= i; last-- {
+			if candidate := strings.TrimPrefix(fileName[bounds[i][0]:bounds[last][1]], "v"); Version(candidate).IsExact() {
+				return candidate, last - i + 1
+			}
+		}
+		return "", 0
+	}
+
+	name = fileName
+	found := false
+	for i := 0; i < len(bounds); i++ {
+		n := 0
+		if partOS, partArch, m := platformPart(i); partOS != "" {
+			goos, n = partOS, m
+		} else if partArch != "" {
+			goarch, n = partArch, m
+		} else if version == "" {
+			version, n = parseVersion(i)
+		}
+		if n == 0 {
+			continue
+		}
+		if !found {
+			found = true
+			// separator preceding the recognized part isn't a part of the name
+			name = ""
+			if i > 0 {
+				name = fileName[:bounds[i-1][1]]
+			}
+		}
+		i += n - 1
+	}
+
+	return name, version, goos, goarch
+}
+
+// DetectPlatform returns OS and architecture of the executable using its
+// headers. Empty strings are returned for scripts and unknown formats.
+func DetectPlatform(fs afero.Fs, binPath string) (goos string, goarch string) {
+	file, err := fs.Open(binPath)
+	if err != nil {
+		return "", ""
+	}
+	defer func() { _ = file.Close() }()
+
+	if f, err := elf.NewFile(file); err == nil {
+		goos = "linux"
+		if f.OSABI == elf.ELFOSABI_FREEBSD {
+			goos = "freebsd"
+		}
+		switch f.Machine {
+		case elf.EM_X86_64:
+			goarch = "amd64"
+		case elf.EM_AARCH64:
+			goarch = "arm64"
+		case elf.EM_386:
+			goarch = "386"
+		case elf.EM_ARM:
+			goarch = "arm"
+		}
+		return goos, goarch
+	}
+	if f, err := macho.NewFile(file); err == nil {
+		switch f.Cpu {
+		case macho.CpuAmd64:
+			goarch = "amd64"
+		case macho.CpuArm64:
+			goarch = "arm64"
+		}
+		return "darwin", goarch
+	}
+	if _, err := macho.NewFatFile(file); err == nil {
+		// universal binaries run on all architectures
+		return "darwin", ""
+	}
+	if f, err := pe.NewFile(file); err == nil {
+		switch f.Machine {
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			goarch = "amd64"
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			goarch = "arm64"
+		case pe.IMAGE_FILE_MACHINE_I386:
+			goarch = "386"
+		}
+		return "windows", goarch
+	}
+
+	return "", ""
+}
+
+// mergeEntry adds the entry to the index or updates the existing entry for
+// the same command version and platform.
+func mergeEntry(index *Index, entry Entry) {
+	for idx := range index.Entries {
+		existing := &index.Entries[idx]
+		if existing.Name != entry.Name || existing.Version != entry.Version || existing.OS != entry.OS || existing.Arch != entry.Arch {
+			continue
+		}
+		if existing.Signature != "" && existing.Checksum != entry.Checksum {
+			log.Warnf("Archive of %s@%s (%s) has changed, its signature has been removed", entry.Name, entry.Version, platformName(entry))
+			existing.Signature = ""
+		}
+		existing.URL = entry.URL
+		existing.Checksum = entry.Checksum
+		for key, value := range entry.Annotations {
+			if _, ok := existing.Annotations[key]; !ok {
+				if existing.Annotations == nil {
+					existing.Annotations = map[string]string{}
+				}
+				existing.Annotations[key] = value
+			}
+		}
+		return
+	}
+
+	index.Entries = append(index.Entries, entry)
+}
+
+func platformName(entry Entry) string {
+	goos, goarch := entry.OS, entry.Arch
+	if goos == "" {
+		goos = "any"
+	}
+	if goarch == "" {
+		goarch = "any"
+	}
+	return goos + "/" + goarch
+}
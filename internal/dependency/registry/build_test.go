@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func createCommandArchive(t *testing.T, commandConfig string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	files := []struct{ name, content string }{
+		{"command.yaml", commandConfig},
+		{"cmd.sh", "#!/bin/sh\necho hello\n"},
+	}
+	for _, file := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: file.name, Mode: 0o755, Size: int64(len(file.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     [4]string
+	}{
+		{"hello.tar.gz", [4]string{"hello", "", "", ""}},
+		{"hello-1.2.0-linux-amd64.tar.gz", [4]string{"hello", "1.2.0", "linux", "amd64"}},
+		{"my_cmd_v2.0.1_Darwin_x86_64.tgz", [4]string{"my_cmd", "2.0.1", "darwin", "amd64"}},
+		{"MyTool-1.0.TAR.GZ", [4]string{"MyTool", "1.0", "", ""}},
+		{"hello-1.2.0-rc.1-linux-x86-64.tar.gz", [4]string{"hello", "1.2.0-rc.1", "linux", "amd64"}},
+		{"hello-v1.2.0-beta-2+build.5.zip", [4]string{"hello", "1.2.0-beta-2+build.5", "", ""}},
+		{"hello-1.2.0_rc1.zip", [4]string{"hello", "1.2.0", "", ""}},
+		{"k8s-tool-2.0.0-linux.tar", [4]string{"k8s-tool", "2.0.0", "linux", ""}},
+		{"hello-world-windows-aarch64.zip", [4]string{"hello-world", "", "windows", "arm64"}},
+		{"linux-amd64.tar", [4]string{"", "", "linux", "amd64"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			name, version, goos, goarch := parseFileName(tt.fileName)
+			if got := [4]string{name, version, goos, goarch}; got != tt.want {
+				t.Errorf("parseFileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	hello := createCommandArchive(t, "apiVersion: klio/v1\nkind: Command\nbinPath: cmd.sh\nversion: 1.2.0\ndescription: says hello\n")
+	world := createCommandArchive(t, "apiVersion: klio/v1\nkind: Command\nbinPath: cmd.sh\nversion: v2.0.0\n")
+	_ = afero.WriteFile(fs, "/dist/hello-1.2.0-linux-amd64.tar.gz", hello, 0o644)
+	_ = afero.WriteFile(fs, "/dist/sub dir/world.tar.gz", world, 0o644)
+	_ = afero.WriteFile(fs, "/dist/README.md", []byte("# Commands\n"), 0o644)
+
+	index := &Index{
+		Annotations: map[string]string{"owner": "team"},
+		Entries: []Entry{
+			{Name: "legacy", Version: "0.1.0", URL: "https://old.example.com/legacy.tar.gz", Checksum: "sha256-legacy"},
+			{
+				Name: "hello", Version: "1.2.0", OS: "linux", Arch: "amd64",
+				URL:         "https://old.example.com/hello.tar.gz",
+				Checksum:    "sha256-old",
+				Signature:   "old-signature",
+				Annotations: map[string]string{"description": "custom", "team": "a"},
+			},
+		},
+	}
+
+	if err := Build(fs, index, "/dist", "https://example.com/commands/", ""); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := &Index{
+		APIVersion:  "klio/v1",
+		Kind:        "Registry",
+		Annotations: map[string]string{"owner": "team"},
+		Entries: []Entry{
+			{Name: "legacy", Version: "0.1.0", URL: "https://old.example.com/legacy.tar.gz", Checksum: "sha256-legacy"},
+			{
+				Name: "hello", Version: "1.2.0", OS: "linux", Arch: "amd64",
+				URL:         "https://example.com/commands/hello-1.2.0-linux-amd64.tar.gz",
+				Checksum:    computeTestChecksum(hello),
+				Annotations: map[string]string{"description": "custom", "team": "a"},
+			},
+			{
+				Name: "world", Version: "2.0.0",
+				URL:      "https://example.com/commands/sub%20dir/world.tar.gz",
+				Checksum: computeTestChecksum(world),
+			},
+		},
+	}
+	if !reflect.DeepEqual(index, want) {
+		t.Errorf("Build() index = %+v, want %+v", index, want)
+	}
+}
+
+func TestBuildWithName(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	hello := createCommandArchive(t, "apiVersion: klio/v1\nkind: Command\nbinPath: cmd.sh\nversion: 1.2.0\n")
+	_ = afero.WriteFile(fs, "/dist/1.2.0-linux-amd64.tar.gz", hello, 0o644)
+
+	index := &Index{}
+	if err := Build(fs, index, "/dist", "https://example.com/", "hello"); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	want := []Entry{{
+		Name: "hello", Version: "1.2.0", OS: "linux", Arch: "amd64",
+		URL:      "https://example.com/1.2.0-linux-amd64.tar.gz",
+		Checksum: computeTestChecksum(hello),
+	}}
+	if !reflect.DeepEqual(index.Entries, want) {
+		t.Errorf("Build() entries = %+v, want %+v", index.Entries, want)
+	}
+}
+
+func TestBuildInvalidArchive(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "/dist/hello-1.0.0.tar.gz", createCommandArchive(t, "kind: Command\n"), 0o644)
+
+	if err := Build(fs, &Index{}, "/dist", "https://example.com/", ""); err == nil {
+		t.Errorf("Build() expected error for command.yaml without binPath")
+	}
+}
+
+func computeTestChecksum(content []byte) string {
+	return fmt.Sprintf("sha256-%x", sha256.Sum256(content))
+}
@@ -12,6 +12,8 @@ type Registry interface {
 	GetHighestBreaking(dep dependency.Dependency) (*Entry, error)
 	GetHighestNonBreaking(dep dependency.Dependency) (*Entry, error)
 	GetExactMatch(dep dependency.Dependency) (*Entry, error)
+	// GetEntries returns all entries of the registry.
+	GetEntries() []Entry
 }
 
 type Index struct {
@@ -25,11 +27,16 @@ type Index struct {
 type Entry struct {
 	Name        string            `yaml:"name"`
 	Version     string            `yaml:"version"`
-	OS          string            `json:"os"`
-	Arch        string            `json:"arch"`
+	OS          string            `yaml:"os,omitempty" json:"os"`
+	Arch        string            `yaml:"arch,omitempty" json:"arch"`
 	Annotations map[string]string `yaml:"annotations"`
 	URL         string            `yaml:"url"`
 	Checksum    string            `yaml:"checksum"`
+	// Format of the file (tar.gz, tar.xz, tar.zst, tar, zip or binary), it is
+	// detected using the URL and the file content if it is empty.
+	Format string `yaml:"format,omitempty"`
+	// Signature is a detached minisign signature of the archive.
+	Signature string `yaml:"signature,omitempty"`
 }
 
 func findHighestMatching(registryEntries []Entry, currentDependency dependency.Dependency, constraintFunction func(version Version) (string, error)) (*Entry, error) {
@@ -42,7 +49,7 @@ func findHighestMatching(registryEntries []Entry, currentDependency dependency.D
 
 	for idx, entry := range registryEntries {
 		ver := Version(entry.Version)
-		if currentDependency.Name == entry.Name && isCompatible(entry) && ver.Match(constraint) && (result == nil || ver.GreaterThan(Version(result.Version)) || isMoreSpecific(entry, *result)) {
+		if currentDependency.Name == entry.Name && IsCompatible(entry) && ver.Match(constraint) && (result == nil || ver.GreaterThan(Version(result.Version)) || isMoreSpecific(entry, *result)) {
 			result = &registryEntries[idx]
 		}
 	}
@@ -50,7 +57,9 @@ func findHighestMatching(registryEntries []Entry, currentDependency dependency.D
 	return result, nil
 }
 
-func isCompatible(entry Entry) bool {
+// IsCompatible checks whether the entry can be installed on the current
+// operating system and architecture.
+func IsCompatible(entry Entry) bool {
 	return (entry.OS == runtime.GOOS || entry.OS == "") && (entry.Arch == runtime.GOARCH || entry.Arch == "")
 }
 
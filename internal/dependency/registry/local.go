@@ -51,7 +51,7 @@ func (reg *local) Update() error {
 }
 
 func (reg *local) GetExactMatch(dep dependency.Dependency) (*Entry, error) {
-	return findHighestMatching(reg.index.Entries, dep, getExactMatch)
+	return findHighestMatching(reg.index.Entries, dep, getRangeConstraints)
 }
 
 func (reg *local) GetHighestBreaking(dep dependency.Dependency) (*Entry, error) {
@@ -61,3 +61,7 @@ func (reg *local) GetHighestBreaking(dep dependency.Dependency) (*Entry, error)
 func (reg *local) GetHighestNonBreaking(dep dependency.Dependency) (*Entry, error) {
 	return findHighestMatching(reg.index.Entries, dep, getMinorAndPatchConstraints)
 }
+
+func (reg *local) GetEntries() []Entry {
+	return reg.index.Entries
+}
@@ -241,3 +241,43 @@ func TestLocalUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalGetExactMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{name: "Exact", version: "1.2.0", want: "1.2.0"},
+		{name: "Any", version: "*", want: "2.1.0"},
+		{name: "Caret", version: "^1.0.0", want: "1.2.0"},
+		{name: "Tilde", version: "~1.0.0", want: "1.0.0"},
+		{name: "GreaterOrEqual", version: ">=1.1.0", want: "2.1.0"},
+		{name: "Alternative", version: "1.0.x || ^2.0.0", want: "2.1.0"},
+		{name: "NotAvailable", version: "^3.0.0"},
+		{name: "Invalid", version: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := &local{
+				path: "/we/are/your/friends/registry.yaml",
+				fs:   getMockFs(),
+			}
+			if err := reg.Update(); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			entry, err := reg.GetExactMatch(dependency.Dependency{Name: "docs", Version: tt.version})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetExactMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (entry != nil) != (tt.want != "") {
+				t.Fatalf("GetExactMatch() got = %v, want %s", entry, tt.want)
+			}
+			if entry != nil && entry.Version != tt.want {
+				t.Errorf("GetExactMatch() got = %s, want %s", entry.Version, tt.want)
+			}
+		})
+	}
+}
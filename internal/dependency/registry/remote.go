@@ -1,62 +1,164 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"time"
 
+	"github.com/g2a-com/klio/internal/auth"
 	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/download"
 	"github.com/g2a-com/klio/internal/log"
 	"gopkg.in/yaml.v3"
 )
 
 // remote represents registry hosted on http server.
 type remote struct {
-	url    string
-	index  Index
-	client *http.Client
+	url     string
+	index   Index
+	client  *http.Client
+	options Options
+}
+
+// Options of a remote registry.
+type Options struct {
+	// CacheDir is a directory in which the last downloaded index is stored.
+	// Index isn't stored if it is empty.
+	CacheDir string
+	// Offline makes registry load the stored index instead of downloading it.
+	Offline bool
+	// Client is used to download the index, http.DefaultClient is used if it is nil.
+	Client *http.Client
+	// Download configures retries of failed requests.
+	Download download.Options
+	// Context cancels downloading the index, context.Background() is used if
+	// it is nil.
+	Context context.Context
 }
 
 // NewRemote returns new registry instance hosted on http server.
-func NewRemote(registryUrl string) Registry {
+func NewRemote(registryUrl string, options Options) Registry {
 	registry := &remote{
-		url:    registryUrl,
-		client: http.DefaultClient,
+		url:     registryUrl,
+		client:  http.DefaultClient,
+		options: options,
+	}
+	if options.Client != nil {
+		registry.client = options.Client
 	}
 
 	return registry
 }
 
 func (reg *remote) Update() error {
-	log.Spamf("Loading registry: %s", reg.url)
-
-	var buffer []byte
+	log.Spamf("Loading registry: %s", auth.RedactURL(reg.url))
 
 	if reg.url == "" {
 		return fmt.Errorf("registry url not set")
 	}
 
-	res, err := reg.client.Get(reg.url)
+	cached, err := reg.loadSnapshot()
+	if err != nil {
+		log.Debugf("Cannot load cached index of registry %s: %s", auth.RedactURL(reg.url), err)
+	}
+
+	if reg.options.Offline {
+		if cached == nil {
+			return fmt.Errorf("registry %s is not available offline, it has never been downloaded", auth.RedactURL(reg.url))
+		}
+		return reg.parse(cached.index)
+	}
+
+	if cached != nil && time.Now().Before(cached.Expires) {
+		log.Spamf("Using cached index of registry %s", auth.RedactURL(reg.url))
+		if err := reg.parse(cached.index); err == nil {
+			return nil
+		}
+	}
+
+	current, err := reg.download(cached)
 	if err != nil {
+		if cached != nil && isTemporary(err) {
+			log.Warnf("Cannot load registry %s, using cached index: %s", auth.RedactURL(reg.url), err)
+			return reg.parse(cached.index)
+		}
 		return err
 	}
+
+	if err := reg.parse(current.index); err != nil {
+		return err
+	}
+
+	reg.saveSnapshot(current)
+
+	return nil
+}
+
+// download fetches index of the registry. Request is conditional if the
+// cached snapshot is provided, in such case it may be returned (with updated
+// expiration time) if the index wasn't modified.
+func (reg *remote) download(cached *snapshot) (*snapshot, error) {
+	ctx := reg.options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reg.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	res, err := download.Do(reg.client, req, reg.options.Download)
+	if err != nil {
+		return nil, err
+	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(res.Body)
 
+	current := &snapshot{
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Expires:      getExpirationTime(res.Header),
+	}
+
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		log.Spamf("Index of registry %s not modified", auth.RedactURL(reg.url))
+		current.index = cached.index
+		if current.ETag == "" {
+			current.ETag = cached.ETag
+		}
+		if current.LastModified == "" {
+			current.LastModified = cached.LastModified
+		}
+		return current, nil
+	}
+
 	if res.StatusCode >= http.StatusMultipleChoices { // 300
-		return fmt.Errorf("registry returned response: %s", res.Status)
+		return nil, &responseError{res.StatusCode, res.Status}
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(res.Body)
-	buffer, err = io.ReadAll(res.Body)
+
+	current.index, err = io.ReadAll(res.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	return current, nil
+}
+
+func (reg *remote) parse(buffer []byte) error {
 	emptyIndex := Index{}
+	reg.index = Index{}
 	if err := yaml.Unmarshal(buffer, &reg.index); err != nil {
 		return err
 	}
@@ -68,8 +170,27 @@ func (reg *remote) Update() error {
 	return nil
 }
 
+// responseError is returned when registry responds with an unexpected status.
+type responseError struct {
+	statusCode int
+	status     string
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("registry returned response: %s", e.status)
+}
+
+// isTemporary checks whether the error is caused by network or server
+// failure, rather than by the registry not existing.
+func isTemporary(err error) bool {
+	if resErr, ok := err.(*responseError); ok {
+		return resErr.statusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
 func (reg *remote) GetExactMatch(dep dependency.Dependency) (*Entry, error) {
-	return findHighestMatching(reg.index.Entries, dep, getExactMatch)
+	return findHighestMatching(reg.index.Entries, dep, getRangeConstraints)
 }
 
 func (reg *remote) GetHighestBreaking(dep dependency.Dependency) (*Entry, error) {
@@ -79,3 +200,7 @@ func (reg *remote) GetHighestBreaking(dep dependency.Dependency) (*Entry, error)
 func (reg *remote) GetHighestNonBreaking(dep dependency.Dependency) (*Entry, error) {
 	return findHighestMatching(reg.index.Entries, dep, getMinorAndPatchConstraints)
 }
+
+func (reg *remote) GetEntries() []Entry {
+	return reg.index.Entries
+}
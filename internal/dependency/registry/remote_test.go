@@ -1,13 +1,17 @@
 package registry
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/download"
 	"gopkg.in/yaml.v3"
 )
 
@@ -163,6 +167,9 @@ func TestRemoteUpdate(t *testing.T) {
 			if err == nil && !reflect.DeepEqual(reg.index, testIndexOne) {
 				t.Errorf("After update got = %v, want %v", reg.index, testIndexOne)
 			}
+			if err == nil && !reflect.DeepEqual(reg.GetEntries(), testIndexOne.Entries) {
+				t.Errorf("GetEntries() got = %v, want %v", reg.GetEntries(), testIndexOne.Entries)
+			}
 
 			dep := dependency.Dependency{
 				Name:    "docs",
@@ -186,3 +193,122 @@ func TestRemoteUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoteUpdateOffline(t *testing.T) {
+	testServer := getMockArtifactory()
+	url := testServer.URL + validUrlPath
+	cacheDir := t.TempDir()
+
+	offlineReg := NewRemote(url, Options{CacheDir: cacheDir, Offline: true})
+	if err := offlineReg.Update(); err == nil {
+		t.Errorf("Update() in offline mode should fail if the index has never been downloaded")
+	}
+
+	if err := NewRemote(url, Options{CacheDir: cacheDir}).Update(); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	testServer.Close()
+
+	if err := offlineReg.Update(); err != nil {
+		t.Fatalf("Update() in offline mode error = %v", err)
+	}
+	if got := offlineReg.(*remote).index; !reflect.DeepEqual(got, testIndexOne) {
+		t.Errorf("Update() in offline mode loaded %v, want %v", got, testIndexOne)
+	}
+}
+
+func TestRemoteUpdateWithCachedIndex(t *testing.T) {
+	index, _ := yaml.Marshal(testIndexOne)
+	var requests, notModified int
+	cacheControl := ""
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", cacheControl)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(index)
+	}))
+	url := testServer.URL + validUrlPath
+	options := Options{CacheDir: t.TempDir()}
+
+	update := func() {
+		t.Helper()
+		reg := NewRemote(url, options)
+		if err := reg.Update(); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if got := reg.(*remote).index; !reflect.DeepEqual(got, testIndexOne) {
+			t.Errorf("Update() loaded %v, want %v", got, testIndexOne)
+		}
+	}
+
+	// index is downloaded and then revalidated with ETag
+	update()
+	update()
+	if requests != 2 || notModified != 1 {
+		t.Errorf("expected 2 requests (1 conditional), got %d (%d conditional)", requests, notModified)
+	}
+
+	// fresh index isn't revalidated
+	cacheControl = "public, max-age=60"
+	update()
+	update()
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+
+	// update fails if neither the registry nor its cached index is available
+	testServer.Close()
+	options.CacheDir = t.TempDir()
+	if err := NewRemote(url, options).Update(); err == nil {
+		t.Errorf("Update() should fail without cached index")
+	}
+}
+
+func TestRemoteUpdateFallback(t *testing.T) {
+	index, _ := yaml.Marshal(testIndexOne)
+	failing := false
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(index)
+	}))
+	defer testServer.Close()
+	url := testServer.URL + validUrlPath
+	options := Options{CacheDir: t.TempDir()}
+
+	if err := NewRemote(url, options).Update(); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	failing = true
+	reg := NewRemote(url, options)
+	if err := reg.Update(); err != nil {
+		t.Fatalf("Update() should use cached index, got error = %v", err)
+	}
+	if got := reg.(*remote).index; !reflect.DeepEqual(got, testIndexOne) {
+		t.Errorf("Update() loaded %v, want %v", got, testIndexOne)
+	}
+}
+
+func TestRemoteUpdateCancelled(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// retries aren't waited for once the context is cancelled
+	options := Options{Context: ctx, Download: download.Options{Retries: 5, Backoff: time.Minute}}
+	err := NewRemote(testServer.URL+validUrlPath, options).Update()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Update() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/g2a-com/klio/internal/auth"
+	"github.com/g2a-com/klio/internal/log"
+)
+
+// snapshot is a copy of a remote registry index stored on disk, together with
+// metadata required to revalidate it.
+type snapshot struct {
+	index        []byte
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Expires      time.Time `json:"expires"`
+}
+
+// loadSnapshot returns the last downloaded index, or nil if there is none.
+func (reg *remote) loadSnapshot() (*snapshot, error) {
+	if reg.options.CacheDir == "" {
+		return nil, nil
+	}
+
+	index, err := os.ReadFile(reg.snapshotPath(".yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// missing or invalid metadata only forces revalidation of the index
+	snap := &snapshot{}
+	if metadata, err := os.ReadFile(reg.snapshotPath(".json")); err == nil {
+		if err := json.Unmarshal(metadata, snap); err != nil {
+			log.Debugf("Cannot parse metadata of cached index of registry %s: %s", auth.RedactURL(reg.url), err)
+			snap = &snapshot{}
+		}
+	}
+	snap.index = index
+
+	return snap, nil
+}
+
+// saveSnapshot stores downloaded index, so it can be reused later and offline.
+// Failures are only logged, since the index has been downloaded anyway.
+func (reg *remote) saveSnapshot(snap *snapshot) {
+	if reg.options.CacheDir == "" {
+		return
+	}
+
+	metadata, err := json.Marshal(snap)
+	if err == nil {
+		err = writeFileAtomically(reg.snapshotPath(".yaml"), snap.index)
+	}
+	if err == nil {
+		err = writeFileAtomically(reg.snapshotPath(".json"), metadata)
+	}
+	if err != nil {
+		log.Debugf("Cannot cache index of registry %s: %s", auth.RedactURL(reg.url), err)
+	}
+}
+
+func (reg *remote) snapshotPath(ext string) string {
+	return filepath.Join(reg.options.CacheDir, fmt.Sprintf("%x%s", sha256.Sum256([]byte(reg.url)), ext))
+}
+
+// getExpirationTime returns time until which response may be used without
+// revalidation, according to its Cache-Control header.
+func getExpirationTime(header http.Header) time.Time {
+	now := time.Now()
+	maxAge := 0
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			return now
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+				maxAge = seconds
+			}
+		}
+	}
+	return now.Add(time.Duration(maxAge) * time.Second)
+}
+
+// writeFileAtomically writes data to a temporary file first and renames it
+// afterwards, so other processes never read partially written files.
+func writeFileAtomically(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+	}
+	return err
+}
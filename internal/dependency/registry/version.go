@@ -20,9 +20,16 @@ func (ver Version) GreaterThan(ver2 Version) bool {
 	return err1 == nil && err2 == nil && v1.GreaterThan(v2)
 }
 
-func getExactMatch(version Version) (string, error) {
-	_, err := semver.NewVersion(string(version))
-	if err != nil && version != "*" {
+// IsExact checks whether ver is a single version rather than a range.
+func (ver Version) IsExact() bool {
+	_, err := semver.NewVersion(string(ver))
+	return err == nil
+}
+
+// getRangeConstraints accepts exact versions as well as semver ranges
+// (e.g. "^1.2.0", "~1.2", ">=1.0.0 <2.0.0", "1.x || 2.x").
+func getRangeConstraints(version Version) (string, error) {
+	if _, err := semver.NewConstraint(string(version)); err != nil {
 		return "", err
 	}
 	return string(version), nil
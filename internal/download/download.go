@@ -0,0 +1,285 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/g2a-com/klio/internal/auth"
+	"github.com/g2a-com/klio/internal/log"
+)
+
+// Options configure timeouts and retries of HTTP requests.
+type Options struct {
+	// ConnectTimeout is the maximum time of establishing a connection.
+	ConnectTimeout time.Duration
+	// ReadTimeout is the maximum time of waiting for a response or for the
+	// next chunk of its body.
+	ReadTimeout time.Duration
+	// Retries is the number of retries of failed requests.
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled after each
+	// subsequent one.
+	Backoff time.Duration
+}
+
+// DefaultOptions are used if options aren't configured by the user.
+var DefaultOptions = Options{
+	ConnectTimeout: 30 * time.Second,
+	ReadTimeout:    60 * time.Second,
+	Retries:        3,
+	Backoff:        time.Second,
+}
+
+// Error is returned when the request fails despite retries.
+type Error struct {
+	URL      string
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	attempts := "1 attempt"
+	if e.Attempts != 1 {
+		attempts = fmt.Sprintf("%d attempts", e.Attempts)
+	}
+	return fmt.Sprintf("cannot download %s (%s): %s", e.URL, attempts, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// File is a destination of downloads. It has to be truncated if a download
+// can't be resumed.
+type File interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// NewTransport returns HTTP transport using connect and read timeouts.
+func NewTransport(options Options) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.ConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: options.ConnectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = options.ConnectTimeout
+	}
+	transport.ResponseHeaderTimeout = options.ReadTimeout
+	return transport
+}
+
+// Do sends the request, retrying it on network errors and server failures.
+// Response of the last attempt is returned if the server keeps failing.
+// Request must not have a body.
+func Do(client *http.Client, req *http.Request, options Options) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := client.Do(req)
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		if err != nil && req.Context().Err() != nil {
+			return nil, &Error{auth.RedactURL(req.URL.String()), attempt, err}
+		}
+		if attempt > options.Retries {
+			if err != nil {
+				return nil, &Error{auth.RedactURL(req.URL.String()), attempt, err}
+			}
+			return res, nil
+		}
+
+		if err == nil {
+			err = fmt.Errorf("server responded: %s", res.Status)
+			_ = res.Body.Close()
+		}
+		if err := wait(req.Context(), req.URL.String(), attempt, err, options); err != nil {
+			return nil, &Error{auth.RedactURL(req.URL.String()), attempt, err}
+		}
+	}
+}
+
+// Download writes content of the URL to the file. Interrupted downloads are
+// resumed using range requests, if the server supports them and the response
+// has a validator (ETag or Last-Modified) ensuring that the content hasn't
+// changed in the meantime. If track isn't
+// nil, it is called with content length of the first response and returned
+// writer receives the downloaded content.
+func Download(ctx context.Context, client *http.Client, url string, file File, options Options, track func(contentLength int64) io.Writer) error {
+	var written int64
+	var validator string
+	var progress io.Writer
+
+	for attempt := 1; ; attempt++ {
+		n, err := downloadAttempt(ctx, client, url, file, written, &validator, options, func(contentLength int64) io.Writer {
+			if track != nil && progress == nil {
+				progress = track(contentLength)
+			}
+			return progress
+		})
+		written += n
+		if err == nil {
+			return nil
+		}
+
+		var permanentErr *permanentError
+		if errors.As(err, &permanentErr) || ctx.Err() != nil || attempt > options.Retries {
+			return &Error{auth.RedactURL(url), attempt, err}
+		}
+		if err := wait(ctx, url, attempt, err, options); err != nil {
+			return &Error{auth.RedactURL(url), attempt, err}
+		}
+	}
+}
+
+// downloadAttempt makes a single request, which continues the download if
+// offset isn't zero and the validator of the previous response is known. It
+// returns number of bytes written at the offset and updates the validator.
+func downloadAttempt(ctx context.Context, client *http.Client, url string, file File, offset int64, validator *string, options Options, track func(contentLength int64) io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, &permanentError{err}
+	}
+	// content is sent from the beginning if it has changed since the previous
+	// attempt, without a validator it can't be checked so it is always sent
+	if offset > 0 && *validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", *validator)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch {
+	case offset > 0 && res.StatusCode == http.StatusPartialContent && getRangeStart(res) == offset:
+		log.Debugf("Resuming download of %s from byte %d", auth.RedactURL(url), offset)
+	case res.StatusCode == http.StatusPartialContent:
+		// content of the response can't be placed at the offset, so the next
+		// attempt requests it from the beginning
+		*validator = ""
+		return 0, fmt.Errorf("server responded with unexpected range %q", res.Header.Get("Content-Range"))
+	case res.StatusCode == http.StatusOK:
+		// server doesn't support ranges or content has changed, so the
+		// download has to start over
+		*validator = getValidator(res)
+		if offset > 0 {
+			log.Debugf("Restarting download of %s", auth.RedactURL(url))
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, &permanentError{err}
+		}
+		if err := file.Truncate(0); err != nil {
+			return 0, &permanentError{err}
+		}
+		n, err := copyBody(file, res, options, cancel, track)
+		return n - offset, err
+	case offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// partially downloaded content is discarded by the next attempt
+		return -offset, fmt.Errorf("server responded: %s", res.Status)
+	case isRetryableStatus(res.StatusCode):
+		return 0, fmt.Errorf("server responded: %s", res.Status)
+	default:
+		return 0, &permanentError{fmt.Errorf("server responded: %s", res.Status)}
+	}
+
+	return copyBody(file, res, options, cancel, track)
+}
+
+// getValidator returns value for the If-Range header identifying content of
+// the response, or an empty string if it can't be identified. Weak ETags
+// can't be used in If-Range.
+func getValidator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// copyBody writes body of the response to the file, the request is cancelled
+// if no data is received within the read timeout.
+func copyBody(file File, res *http.Response, options Options, cancel context.CancelFunc, track func(contentLength int64) io.Writer) (int64, error) {
+	var writer io.Writer = file
+	if progress := track(res.ContentLength); progress != nil {
+		writer = io.MultiWriter(file, progress)
+	}
+
+	body := io.Reader(res.Body)
+	if options.ReadTimeout > 0 {
+		timeoutBody := &timeoutReader{r: res.Body, timeout: options.ReadTimeout, timer: time.AfterFunc(options.ReadTimeout, cancel)}
+		defer timeoutBody.timer.Stop()
+		body = timeoutBody
+	}
+
+	n, err := io.Copy(writer, body)
+	if timeoutBody, ok := body.(*timeoutReader); ok && err != nil && !timeoutBody.timer.Stop() {
+		err = fmt.Errorf("no data received for %s", options.ReadTimeout)
+	}
+	return n, err
+}
+
+// timeoutReader restarts the timer whenever data is read.
+type timeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.timer.Reset(t.timeout)
+	}
+	return n, err
+}
+
+// permanentError is an error which won't be fixed by retrying the request.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// wait sleeps before the next attempt, it returns an error if ctx is done.
+func wait(ctx context.Context, url string, attempt int, err error, options Options) error {
+	delay := options.Backoff << (attempt - 1)
+	log.Verbosef("Request to %s failed (attempt %d): %s, retrying in %s", auth.RedactURL(url), attempt, err, delay)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// getRangeStart returns first byte of the range from the Content-Range header.
+func getRangeStart(res *http.Response) int64 {
+	contentRange := strings.TrimPrefix(res.Header.Get("Content-Range"), "bytes ")
+	start, _, _ := strings.Cut(contentRange, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,201 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	content     = bytes.Repeat([]byte("0123456789"), 1000)
+	testOptions = Options{Retries: 2, ReadTimeout: time.Second}
+)
+
+func createFile(t *testing.T) *os.File {
+	file, err := os.Create(filepath.Join(t.TempDir(), "download"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+	return file
+}
+
+func checkContent(t *testing.T, file *os.File) {
+	t.Helper()
+	got, err := os.ReadFile(file.Name())
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes, want %d (error = %v)", len(got), len(content), err)
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer testServer.Close()
+
+	file := createFile(t)
+	if err := Download(context.Background(), testServer.Client(), testServer.URL, file, testOptions, nil); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	checkContent(t, file)
+	if attempts != 3 {
+		t.Errorf("Download() made %d attempts, want 3", attempts)
+	}
+
+	attempts = -10
+	err := Download(context.Background(), testServer.Client(), testServer.URL+"/cmd.tar.gz", file, testOptions, nil)
+	var downloadErr *Error
+	if !errors.As(err, &downloadErr) || downloadErr.Attempts != 3 || !strings.Contains(err.Error(), testServer.URL+"/cmd.tar.gz") {
+		t.Errorf("Download() error = %v, want error naming the URL and 3 attempts", err)
+	}
+}
+
+func TestDownloadDoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer testServer.Close()
+
+	err := Download(context.Background(), testServer.Client(), testServer.URL, createFile(t), testOptions, nil)
+	if err == nil || attempts != 1 || !strings.Contains(err.Error(), "1 attempt") {
+		t.Errorf("Download() error = %v after %d attempts, want error after 1 attempt", err, attempts)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	changed := bytes.Repeat([]byte("9876543210"), 1000)
+	tests := []struct {
+		name string
+		// etag of the first and the next responses
+		etags      []string
+		wantRanges []string
+		want       []byte
+	}{
+		{name: "Unchanged", etags: []string{`"v1"`, `"v1"`}, wantRanges: []string{"", "bytes=5000-"}, want: content},
+		// content mustn't be spliced from different versions
+		{name: "Changed", etags: []string{`"v1"`, `"v2"`}, wantRanges: []string{"", "bytes=5000-"}, want: changed},
+		{name: "WithoutValidator", etags: []string{"", ""}, wantRanges: []string{"", ""}, want: content},
+		{name: "WeakETag", etags: []string{`W/"v1"`, `W/"v1"`}, wantRanges: []string{"", ""}, want: content},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if etag := tt.etags[len(ranges)-1]; etag != "" {
+					w.Header().Set("ETag", etag)
+				}
+				if len(ranges) == 1 {
+					// send a half of the content and break the connection
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					_, _ = w.Write(content[:len(content)/2])
+					w.(http.Flusher).Flush()
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					_ = conn.Close()
+					return
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(tt.want))
+			}))
+			defer testServer.Close()
+
+			file := createFile(t)
+			if err := Download(context.Background(), testServer.Client(), testServer.URL, file, testOptions, nil); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if got, _ := os.ReadFile(file.Name()); !bytes.Equal(got, tt.want) {
+				t.Errorf("Download() wrote unexpected content")
+			}
+			if strings.Join(ranges, ",") != strings.Join(tt.wantRanges, ",") {
+				t.Errorf("Download() made requests with ranges %q, want %q", ranges, tt.wantRanges)
+			}
+		})
+	}
+}
+
+func TestDownloadResumeUnexpectedRange(t *testing.T) {
+	var ranges []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		switch {
+		case len(ranges) == 1:
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		case r.Header.Get("Range") != "":
+			// range different from the requested one
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 100-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(content[100:])
+		default:
+			_, _ = w.Write(content)
+		}
+	}))
+	defer testServer.Close()
+
+	file := createFile(t)
+	if err := Download(context.Background(), testServer.Client(), testServer.URL, file, testOptions, nil); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	checkContent(t, file)
+	if want := []string{"", "bytes=5000-", ""}; strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("Download() made requests with ranges %q, want %q", ranges, want)
+	}
+}
+
+func TestDownloadReadTimeout(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content[:10])
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer testServer.Close()
+
+	options := Options{ReadTimeout: 100 * time.Millisecond}
+	err := Download(context.Background(), testServer.Client(), testServer.URL, createFile(t), options, nil)
+	if err == nil || !strings.Contains(err.Error(), "no data received") {
+		t.Errorf("Download() error = %v, want read timeout", err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, testServer.URL, nil)
+	res, err := Do(testServer.Client(), req, testOptions)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || attempts != 3 {
+		t.Errorf("Do() returned %s after %d attempts, want 503 after 3 attempts", res.Status, attempts)
+	}
+}
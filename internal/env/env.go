@@ -4,4 +4,11 @@ const (
 	KLIO_LOG_LEVEL                          = "KLIO_LOG_LEVEL"
 	KLIO_SKIP_UPDATE_CHECK                  = "KLIO_SKIP_UPDATE_CHECK"
 	KLIO_SKIP_PROJECT_COMMAND_AUTO_DOWNLOAD = "KLIO_SKIP_PROJECT_COMMAND_AUTO_DOWNLOAD"
+	KLIO_INSTALL_JOBS                       = "KLIO_INSTALL_JOBS"
+	KLIO_OFFLINE                            = "KLIO_OFFLINE"
+	KLIO_LOCK_TIMEOUT                       = "KLIO_LOCK_TIMEOUT"
+	KLIO_AUTO_INIT                          = "KLIO_AUTO_INIT"
+	// KLIO_REGISTRY_TOKEN_PREFIX followed by a host name (upper-cased, with
+	// non-alphanumeric characters replaced by "_") specifies bearer token for the host.
+	KLIO_REGISTRY_TOKEN_PREFIX = "KLIO_REGISTRY_TOKEN_"
 )
@@ -1,47 +1,254 @@
 package lock
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/g2a-com/klio/internal/log"
 	"github.com/nightlyone/lockfile"
 )
 
+const (
+	ownerFileSuffix = ".owner"
+	minRetryDelay   = 50 * time.Millisecond
+	maxRetryDelay   = time.Second
+)
+
 type Lock interface {
-	Acquire() error
+	// Acquire waits until the lock is acquired, the timeout passes or ctx is
+	// cancelled.
+	Acquire(ctx context.Context) error
 	Release() error
 }
 
+// Options of a lock.
+type Options struct {
+	// Timeout is the maximum time of waiting for the lock, zero means no limit.
+	Timeout time.Duration
+	// StaleAfter is the time after which the lock is checked for being
+	// abandoned: it is broken if its owner was running on the same host and
+	// doesn't exist anymore. Zero disables this check. Locks containing PIDs
+	// of processes which don't exist anymore are always broken.
+	StaleAfter time.Duration
+}
+
+// DefaultOptions are used if options aren't configured by the user.
+var DefaultOptions = Options{
+	Timeout:    5 * time.Minute,
+	StaleAfter: 10 * time.Minute,
+}
+
+// Owner describes process holding the lock.
+type Owner struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+}
+
+func (o *Owner) String() string {
+	return fmt.Sprintf(`process %d ("%s") on %s since %s`, o.PID, o.Command, o.Host, o.Since.Format(time.RFC3339))
+}
+
+// TimeoutError is returned when the lock can't be acquired in time.
+type TimeoutError struct {
+	Path  string
+	Owner string
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("cannot acquire lock %s held by %s: %s", e.Path, e.Owner, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
 type lock struct {
-	lockFile lockfile.Lockfile
+	lockFile  lockfile.Lockfile
+	ownerPath string
+	options   Options
+	// reportedStale is set once the user is told about the lock held for
+	// longer than StaleAfter by a running process.
+	reportedStale bool
 }
 
-func New(lockPath string) (Lock, error) {
+// Status describes a lock file found on disk.
+type Status struct {
+	Path string
+	// Held is true if the lock is held by a running process.
+	Held bool
+	// Owner describes the process which holds (or held) the lock.
+	Owner string
+	// Age is the time since the lock was acquired.
+	Age time.Duration
+}
+
+// Inspect returns status of the lock file, or nil if there is no lock file.
+func Inspect(lockPath string) (*Status, error) {
+	info, err := os.Lstat(lockPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	l, err := lockfile.New(lockPath)
 	if err != nil {
 		return nil, err
 	}
-	return &lock{lockFile: l}, nil
+
+	_, err = l.GetOwner()
+	return &Status{
+		Path:  lockPath,
+		Held:  err == nil,
+		Owner: (&lock{lockFile: l, ownerPath: lockPath + ownerFileSuffix}).describeOwner(),
+		Age:   time.Since(info.ModTime()),
+	}, nil
 }
 
-func (l *lock) Acquire() error {
-	err := l.lockFile.TryLock()
+func New(lockPath string, options Options) (Lock, error) {
+	l, err := lockfile.New(lockPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	lockOwner, err := l.lockFile.GetOwner()
-	if err != nil {
-		return err
+	return &lock{lockFile: l, ownerPath: lockPath + ownerFileSuffix, options: options}, nil
+}
+
+func (l *lock) Acquire(ctx context.Context) error {
+	if l.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.options.Timeout)
+		defer cancel()
+	}
+
+	delay := minRetryDelay
+	waiting := false
+	for {
+		err := l.lockFile.TryLock()
+		if err == nil {
+			l.writeOwner()
+			log.Debugf("acquired lock %s", l.lockFile)
+			return nil
+		}
+
+		var temporaryErr interface{ Temporary() bool }
+		if !errors.As(err, &temporaryErr) || !temporaryErr.Temporary() {
+			return err
+		}
+
+		if l.breakIfStale() {
+			continue
+		}
+
+		if !waiting {
+			log.Infof("Waiting for lock %s held by %s", l.lockFile, l.describeOwner())
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return &TimeoutError{string(l.lockFile), l.describeOwner(), ctx.Err()}
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
 	}
-	log.Debugf("acquiring lock for process %d", lockOwner.Pid)
-	return nil
 }
 
 func (l *lock) Release() error {
-	lockOwner, err := l.lockFile.GetOwner()
-	if err != nil {
+	_ = os.Remove(l.ownerPath)
+	if err := l.lockFile.Unlock(); err != nil {
 		return fmt.Errorf("failed releasing a lock: %v", err)
 	}
-	log.Debugf("releasing lock for process %d", lockOwner.Pid)
-	return l.lockFile.Unlock()
+	log.Debugf("released lock %s", l.lockFile)
+	return nil
+}
+
+// writeOwner stores information about the current process, so other processes
+// can tell who holds the lock. It is informative only, so failures are ignored.
+func (l *lock) writeOwner() {
+	host, _ := os.Hostname()
+	command := filepath.Base(os.Args[0])
+	if len(os.Args) > 1 {
+		// other arguments are omitted, since they may contain credentials
+		command += " " + os.Args[1]
+	}
+	data, _ := json.Marshal(&Owner{PID: os.Getpid(), Host: host, Command: command, Since: time.Now()})
+	if err := os.WriteFile(l.ownerPath, data, 0o644); err != nil {
+		log.Debugf("Cannot write owner of lock %s: %s", l.lockFile, err)
+	}
+}
+
+func (l *lock) readOwner() (*Owner, error) {
+	data, err := os.ReadFile(l.ownerPath)
+	if err != nil {
+		return nil, err
+	}
+	owner := &Owner{}
+	if err := json.Unmarshal(data, owner); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// describeOwner returns human-readable description of the process holding
+// the lock.
+func (l *lock) describeOwner() string {
+	owner, err := l.readOwner()
+	if err == nil {
+		if process, err := l.lockFile.GetOwner(); err == nil && process.Pid == owner.PID {
+			return owner.String()
+		}
+	}
+	if process, err := l.lockFile.GetOwner(); err == nil {
+		return fmt.Sprintf("process %d", process.Pid)
+	}
+	return "unknown process"
+}
+
+// breakIfStale removes the lock if it has been held for longer than allowed
+// by a process which doesn't exist anymore. Locks of running processes and
+// processes on other hosts (e.g. sharing the directory over a network) are
+// never broken. It returns true if the lock was removed.
+func (l *lock) breakIfStale() bool {
+	if l.options.StaleAfter <= 0 {
+		return false
+	}
+	info, err := os.Lstat(string(l.lockFile))
+	if err != nil || time.Since(info.ModTime()) < l.options.StaleAfter {
+		return false
+	}
+
+	holder := l.describeOwner()
+	owner, err := l.readOwner()
+	host, _ := os.Hostname()
+	if err != nil || owner.Host != host || isRunning(owner.PID) {
+		if !l.reportedStale {
+			log.Warnf("Lock %s has been held by %s for more than %s, it may be removed by hand if that process hangs", l.lockFile, holder, l.options.StaleAfter)
+			l.reportedStale = true
+		}
+		return false
+	}
+
+	// lock is moved aside before removing, so lock acquired by another
+	// process in the meantime isn't removed by mistake
+	stalePath := fmt.Sprintf("%s.stale-%d", l.lockFile, os.Getpid())
+	if err := os.Rename(string(l.lockFile), stalePath); err != nil {
+		return false
+	}
+	defer func() { _ = os.Remove(stalePath) }()
+	staleInfo, err := os.Lstat(stalePath)
+	if err != nil || !os.SameFile(info, staleInfo) {
+		_ = os.Link(stalePath, string(l.lockFile))
+		return false
+	}
+
+	log.Warnf("Breaking lock %s left by %s, which doesn't exist anymore", l.lockFile, owner)
+	_ = os.Remove(l.ownerPath)
+	return true
 }
@@ -0,0 +1,126 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// createForeignLock creates lock held by the parent process, which is running
+// but isn't the current one.
+func createForeignLock(t *testing.T) string {
+	lockPath := filepath.Join(t.TempDir(), "test.lock")
+	if err := os.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", os.Getppid())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return lockPath
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	lockPath := createForeignLock(t)
+	l, _ := New(lockPath, Options{Timeout: 200 * time.Millisecond, StaleAfter: time.Hour})
+
+	start := time.Now()
+	err := l.Acquire(context.Background())
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want TimeoutError", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Acquire() returned after %s, before the timeout", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+// deadPID returns PID of a process which has already exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	host, _ := os.Hostname()
+	tests := []struct {
+		name      string
+		owner     Owner
+		wantBreak bool
+	}{
+		{name: "RunningOwner", owner: Owner{PID: os.Getppid(), Host: host}, wantBreak: false},
+		{name: "DeadOwner", owner: Owner{PID: deadPID(t), Host: host}, wantBreak: true},
+		{name: "DeadOwnerOnOtherHost", owner: Owner{PID: deadPID(t), Host: host + ".other"}, wantBreak: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// lock file itself points to a running process, as if its PID was
+			// reused, so only the owner file tells whether the lock is stale
+			lockPath := createForeignLock(t)
+			data, _ := json.Marshal(&tt.owner)
+			if err := os.WriteFile(lockPath+ownerFileSuffix, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			staleTime := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(lockPath, staleTime, staleTime); err != nil {
+				t.Fatal(err)
+			}
+			l, _ := New(lockPath, Options{Timeout: 200 * time.Millisecond, StaleAfter: time.Minute})
+
+			err := l.Acquire(context.Background())
+			if !tt.wantBreak {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("Acquire() error = %v, lock shouldn't be broken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Acquire() error = %v", err)
+			}
+			owner, err := l.(*lock).readOwner()
+			if err != nil || owner.PID != os.Getpid() {
+				t.Errorf("Acquire() should record the owner, got %v (error = %v)", owner, err)
+			}
+
+			if err := l.Release(); err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+			for _, path := range []string{lockPath, lockPath + ownerFileSuffix} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("Release() should remove %s", path)
+				}
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	lockPath := createForeignLock(t)
+	status, err := Inspect(lockPath)
+	if err != nil || status == nil || !status.Held || status.Owner != fmt.Sprintf("process %d", os.Getppid()) {
+		t.Errorf("Inspect() = %+v (error = %v), want lock held by the parent process", status, err)
+	}
+
+	// pid which can't belong to any process
+	if err := os.WriteFile(lockPath, []byte("2147483647\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := Inspect(lockPath); err != nil || status == nil || status.Held {
+		t.Errorf("Inspect() = %+v (error = %v), want lock which isn't held", status, err)
+	}
+
+	if status, err := Inspect(lockPath + ".missing"); err != nil || status != nil {
+		t.Errorf("Inspect() = %+v (error = %v), want nil for missing lock", status, err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package lock
+
+import (
+	"os"
+	"syscall"
+)
+
+// isRunning checks whether the process with the given PID exists.
+func isRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// signal 0 checks permissions and existence of the process only
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,26 @@
+package lock
+
+import "syscall"
+
+const (
+	// stillActive is the exit code of processes which haven't exited yet.
+	stillActive = 259
+	// errorInvalidParameter is returned for PIDs of processes which don't exist.
+	errorInvalidParameter syscall.Errno = 87
+)
+
+// isRunning checks whether the process with the given PID exists. Processes
+// which can't be queried are assumed to be running.
+func isRunning(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err != errorInvalidParameter
+	}
+	defer func() { _ = syscall.CloseHandle(handle) }()
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,119 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/g2a-com/klio/internal/config"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/registry"
+)
+
+const (
+	lockAPIVersion = "klio/v1"
+	lockKind       = "Lock"
+)
+
+// Lock describes structure of klio.lock files. It records exact artifacts
+// installed for every dependency listed in the project config.
+type Lock struct {
+	Meta         config.Metadata                     `yaml:"-"`
+	APIVersion   string                              `yaml:"apiVersion"`
+	Kind         string                              `yaml:"kind"`
+	Dependencies []dependency.DependenciesIndexEntry `yaml:"dependencies"`
+}
+
+// LoadLock reads a project lock file. Missing file results in an empty lock.
+func LoadLock(filePath string) (*Lock, error) {
+	lock := &Lock{}
+	if err := config.LoadConfigFile(lock, &lock.Meta, filePath); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// SaveLock saves a project lock file.
+func SaveLock(lock *Lock) error {
+	lock.APIVersion = lockAPIVersion
+	lock.Kind = lockKind
+	sort.Slice(lock.Dependencies, func(i, j int) bool {
+		return lock.Dependencies[i].Alias < lock.Dependencies[j].Alias
+	})
+	return config.SaveConfigFile(lock, lock.Meta.Path)
+}
+
+// GetDependency returns locked entry for the alias or nil if there is none.
+func (l *Lock) GetDependency(alias string) *dependency.DependenciesIndexEntry {
+	for _, entry := range l.Dependencies {
+		if entry.Alias == alias {
+			return &entry
+		}
+	}
+	return nil
+}
+
+// Pin returns a copy of the dependency with the version set to the locked one,
+// as long as the lock entry refers to the same command and satisfies the declared
+// version. Otherwise dependency is returned unchanged.
+func (l *Lock) Pin(dep dependency.Dependency) dependency.Dependency {
+	entry := l.GetDependency(dep.Alias)
+	if entry != nil && entry.Name == dep.Name && entry.Registry == dep.Registry && satisfies(entry.Version, dep.Version) {
+		dep.Version = entry.Version
+	}
+	return dep
+}
+
+// Update replaces lock entries for installed dependencies and drops entries
+// for aliases which are no longer present in the project config.
+func (l *Lock) Update(installed []dependency.DependenciesIndexEntry, projectConfig *Config) {
+	entries := map[string]dependency.DependenciesIndexEntry{}
+	for _, entry := range l.Dependencies {
+		entries[entry.Alias] = entry
+	}
+	for _, entry := range installed {
+		entry.Path = ""
+		entries[entry.Alias] = entry
+	}
+
+	l.Dependencies = nil
+	for alias, entry := range entries {
+		if projectConfig.GetDependency(alias) != nil {
+			l.Dependencies = append(l.Dependencies, entry)
+		}
+	}
+}
+
+// Verify checks whether the lock is in sync with the project config.
+func (l *Lock) Verify(projectConfig *Config) error {
+	if !l.Meta.Exists {
+		return fmt.Errorf("lock file %s does not exist", l.Meta.Path)
+	}
+	for _, dep := range projectConfig.Dependencies {
+		entry := l.GetDependency(dep.Alias)
+		switch {
+		case entry == nil:
+			return fmt.Errorf("%s is not locked", dep.Alias)
+		case entry.Name != dep.Name:
+			return fmt.Errorf("%s is locked to command %s, but project config requires %s", dep.Alias, entry.Name, dep.Name)
+		case entry.Registry != dep.Registry:
+			return fmt.Errorf("%s is locked to registry %s, but project config requires %s", dep.Alias, entry.Registry, dep.Registry)
+		case !satisfies(entry.Version, dep.Version):
+			return fmt.Errorf("%s is locked to version %s, but project config requires %s", dep.Alias, entry.Version, dep.Version)
+		}
+	}
+	for _, entry := range l.Dependencies {
+		if projectConfig.GetDependency(entry.Alias) == nil {
+			return fmt.Errorf("%s is locked, but it is missing in project config", entry.Alias)
+		}
+	}
+	return nil
+}
+
+// satisfies checks whether version matches the constraint. Empty constraint
+// matches any version.
+func satisfies(version string, constraint string) bool {
+	if constraint == "" {
+		constraint = "*"
+	}
+	return registry.Version(version).Match(constraint)
+}
@@ -0,0 +1,95 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/g2a-com/klio/internal/config"
+	"github.com/g2a-com/klio/internal/dependency"
+)
+
+var testLock = Lock{
+	Meta: config.Metadata{Exists: true},
+	Dependencies: []dependency.DependenciesIndexEntry{
+		{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "1.2.0", Checksum: "sha256-abc"},
+	},
+}
+
+func TestLockPin(t *testing.T) {
+	tests := []struct {
+		name string
+		dep  dependency.Dependency
+		want string
+	}{
+		{
+			name: "should pin any version",
+			dep:  dependency.Dependency{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "*"},
+			want: "1.2.0",
+		},
+		{
+			name: "should pin matching range",
+			dep:  dependency.Dependency{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "^1.0.0"},
+			want: "1.2.0",
+		},
+		{
+			name: "should not pin version outside of range",
+			dep:  dependency.Dependency{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "2.0.0"},
+			want: "2.0.0",
+		},
+		{
+			name: "should not pin command from other registry",
+			dep:  dependency.Dependency{Alias: "docs", Name: "docs", Registry: "https://example.org/registry.yaml", Version: "*"},
+			want: "*",
+		},
+		{
+			name: "should not pin other command",
+			dep:  dependency.Dependency{Alias: "docs", Name: "documentation", Registry: "https://example.com/registry.yaml", Version: "*"},
+			want: "*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testLock.Pin(tt.dep); got.Version != tt.want {
+				t.Errorf("Pin() version = %s, want %s", got.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    []dependency.Dependency
+		wantErr bool
+	}{
+		{
+			name: "should pass when in sync",
+			deps: []dependency.Dependency{{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "^1.0.0"}},
+		},
+		{
+			name:    "should fail on version mismatch",
+			deps:    []dependency.Dependency{{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "1.3.0"}},
+			wantErr: true,
+		},
+		{
+			name: "should fail on missing lock entry",
+			deps: []dependency.Dependency{
+				{Alias: "docs", Name: "docs", Registry: "https://example.com/registry.yaml", Version: "1.2.0"},
+				{Alias: "hello", Name: "hello", Registry: "https://example.com/registry.yaml", Version: "1.0.0"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on extra lock entry",
+			deps:    []dependency.Dependency{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testLock.Verify(&Config{Dependencies: tt.deps})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
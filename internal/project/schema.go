@@ -2,9 +2,12 @@ package project
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/g2a-com/klio/internal/config"
 	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/dependency/registry"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,7 +16,14 @@ type Config struct {
 	Meta            config.Metadata
 	DefaultRegistry string
 	Dependencies    []dependency.Dependency
-	yaml            *yaml.Node
+	// TrustedKeys lists public keys used to verify signatures of archives, by
+	// registry URL.
+	TrustedKeys map[string][]string
+	// RequireChecksums and TrustOnFirstUse enable checksum policies, in
+	// addition to ones enabled in user settings.
+	RequireChecksums bool
+	TrustOnFirstUse  bool
+	yaml             *yaml.Node
 }
 
 func NewDefaultConfig() *Config {
@@ -65,6 +75,7 @@ func (p *Config) MarshalYAML() (interface{}, error) {
 
 	_ = defaultRegistryValueNode.Encode(&p.DefaultRegistry)
 	// Encode dependencies
+	var aliases []string
 	dependencies := map[string]dependency.Dependency{}
 	for _, d := range p.Dependencies {
 		key := d.Alias
@@ -75,14 +86,116 @@ func (p *Config) MarshalYAML() (interface{}, error) {
 		if d.Registry == p.DefaultRegistry {
 			d.Registry = ""
 		}
+		if _, ok := dependencies[key]; !ok {
+			aliases = append(aliases, key)
+		}
 		dependencies[key] = d
 	}
-	_ = dependenciesValueNode.Encode(dependencies)
+	encodeDependencies(dependenciesValueNode, aliases, dependencies)
 
 	// Return result
 	return p.yaml, nil
 }
 
+// encodeDependencies updates dependencies node in place, so formatting and
+// comments of the original file are preserved as much as possible.
+func encodeDependencies(node *yaml.Node, aliases []string, dependencies map[string]dependency.Dependency) {
+	if node.Kind != yaml.MappingNode {
+		_ = node.Encode(dependencies)
+		return
+	}
+
+	// Empty mapping is written in the flow style ("{}"), switch to the block
+	// style before adding any dependency
+	if len(node.Content) == 0 {
+		node.Style = 0
+	}
+
+	// Update or remove existing dependencies
+	var content []*yaml.Node
+	encoded := map[string]bool{}
+	for i := 0; i < len(node.Content)/2; i++ {
+		k := node.Content[i*2]
+		v := node.Content[i*2+1]
+
+		d, ok := dependencies[k.Value]
+		if !ok || encoded[k.Value] {
+			continue
+		}
+		encodeDependency(v, d)
+		encoded[k.Value] = true
+		content = append(content, k, v)
+	}
+
+	// Append new dependencies
+	for _, alias := range aliases {
+		if encoded[alias] {
+			continue
+		}
+		v := &yaml.Node{}
+		_ = v.Encode(dependencies[alias])
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: alias}, v)
+	}
+
+	node.Content = content
+}
+
+// encodeDependency updates fields of a single dependency node in place.
+func encodeDependency(node *yaml.Node, d dependency.Dependency) {
+	if node.Kind != yaml.MappingNode {
+		_ = node.Encode(d)
+		return
+	}
+
+	fields := []struct {
+		key       string
+		value     string
+		omitEmpty bool
+	}{
+		{"name", d.Name, true},
+		{"registry", d.Registry, true},
+		{"version", d.Version, false},
+	}
+
+	for _, field := range fields {
+		idx := -1
+		for i := 0; i < len(node.Content)/2; i++ {
+			if node.Content[i*2].Value == field.key {
+				idx = i * 2
+				break
+			}
+		}
+
+		switch {
+		case field.value == "" && field.omitEmpty && idx >= 0:
+			node.Content = append(node.Content[:idx], node.Content[idx+2:]...)
+		case field.value == "" && field.omitEmpty:
+			continue
+		case idx >= 0:
+			encodeScalar(node.Content[idx+1], field.value)
+		default:
+			v := &yaml.Node{}
+			_ = v.Encode(field.value)
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.key}, v)
+		}
+	}
+}
+
+// encodeScalar sets a value of the node, keeping its style if possible.
+func encodeScalar(node *yaml.Node, value string) {
+	v := &yaml.Node{}
+	_ = v.Encode(value)
+	if node.Kind != yaml.ScalarNode {
+		*node = *v
+		return
+	}
+	node.Value = v.Value
+	node.Tag = v.Tag
+	if v.Style != 0 {
+		node.Style = v.Style
+	}
+}
+
 func (p *Config) GetDependency(dependencyName string) *dependency.Dependency {
 	for _, dep := range p.Dependencies {
 		if dep.Alias == dependencyName {
@@ -92,6 +205,49 @@ func (p *Config) GetDependency(dependencyName string) *dependency.Dependency {
 	return nil
 }
 
+// SetDependency adds the installed dependency to the config or updates the
+// existing one. Declared version constraint is preserved as long as it's
+// satisfied by the installed version, new dependencies requested without
+// a specific version are saved with a caret range (e.g. "^1.2.0").
+func (p *Config) SetDependency(dep dependency.Dependency, installedVersion string) {
+	for i, existing := range p.Dependencies {
+		if existing.Alias != dep.Alias {
+			continue
+		}
+		switch {
+		case existing.Name == dep.Name && existing.Registry == dep.Registry && satisfies(installedVersion, existing.Version):
+			dep.Version = existing.Version
+		case isAnyVersion(dep.Version):
+			dep.Version = "^" + installedVersion
+		case registry.Version(dep.Version).IsExact():
+			dep.Version = bumpConstraint(existing.Version, dep.Version)
+		}
+		p.Dependencies[i] = dep
+		return
+	}
+
+	if isAnyVersion(dep.Version) {
+		dep.Version = "^" + installedVersion
+	}
+	p.Dependencies = append(p.Dependencies, dep)
+}
+
+func isAnyVersion(version string) bool {
+	return version == "" || version == "*"
+}
+
+// bumpConstraint replaces version in a simple caret or tilde range, so the
+// range operator chosen by the user is kept (e.g. "^1.2.0" -> "^2.0.0").
+// Other constraints are replaced with the version itself.
+func bumpConstraint(constraint string, version string) string {
+	for _, operator := range []string{"^", "~"} {
+		if strings.HasPrefix(constraint, operator) && registry.Version(strings.TrimPrefix(constraint, operator)).IsExact() {
+			return operator + version
+		}
+	}
+	return version
+}
+
 func minimalConfig() *yaml.Node {
 	return &yaml.Node{
 		Kind: yaml.MappingNode,
@@ -127,6 +283,18 @@ func (p *Config) UnmarshalYAML(node *yaml.Node) error {
 		switch k.Value {
 		case "defaultRegistry":
 			_ = v.Decode(&p.DefaultRegistry)
+		case "requireChecksums":
+			if err := v.Decode(&p.RequireChecksums); err != nil {
+				return fmt.Errorf("invalid requireChecksums: %s", err)
+			}
+		case "trustOnFirstUse":
+			if err := v.Decode(&p.TrustOnFirstUse); err != nil {
+				return fmt.Errorf("invalid trustOnFirstUse: %s", err)
+			}
+		case "trustedKeys":
+			if err := v.Decode(&p.TrustedKeys); err != nil {
+				return fmt.Errorf("invalid trustedKeys: %s", err)
+			}
 		case "dependencies":
 			aux := map[string]dependency.Dependency{}
 			_ = v.Decode(&aux)
@@ -0,0 +1,64 @@
+package project
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/g2a-com/klio/internal/dependency"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigMarshalYAMLPreservesFormatting(t *testing.T) {
+	input := `# project commands
+defaultRegistry: https://example.com/registry.yaml
+dependencies:
+  # documentation generator
+  docs:
+    version: '1.0.0' # pinned on purpose
+  hello:
+    version: 1.2.0
+  removed:
+    version: 1.0.0
+`
+	want := `# project commands
+defaultRegistry: https://example.com/registry.yaml
+dependencies:
+  # documentation generator
+  docs:
+    version: '1.1.0' # pinned on purpose
+  hello:
+    version: 1.2.0
+    registry: https://example.org/registry.yaml
+  new:
+    version: 2.0.0
+`
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal([]byte(input), cfg); err != nil {
+		t.Fatalf("can't unmarshal config: %s", err)
+	}
+
+	var deps []dependency.Dependency
+	for _, d := range cfg.Dependencies {
+		switch d.Alias {
+		case "docs":
+			d.Version = "1.1.0"
+		case "hello":
+			d.Registry = "https://example.org/registry.yaml"
+		case "removed":
+			continue
+		}
+		deps = append(deps, d)
+	}
+	cfg.Dependencies = append(deps, dependency.Dependency{Alias: "new", Name: "new", Registry: cfg.DefaultRegistry, Version: "2.0.0"})
+
+	var out strings.Builder
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		t.Fatalf("can't marshal config: %s", err)
+	}
+	if out.String() != want {
+		t.Errorf("MarshalYAML() got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -3,8 +3,10 @@ package project
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/g2a-com/klio/internal/config"
+	"gopkg.in/yaml.v3"
 )
 
 // LoadProjectConfig reads a project configuration file.
@@ -42,3 +44,49 @@ func CreateDefaultProjectConfig(filePath string) (*Config, error) {
 
 	return projectConfig, nil
 }
+
+// NewProjectConfig returns Config without dependencies using the given
+// default registry.
+func NewProjectConfig(defaultRegistry string) *Config {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	registryNode := &yaml.Node{}
+	_ = registryNode.Encode(defaultRegistry)
+	node.Content = append(
+		node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "defaultRegistry"},
+		registryNode,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "dependencies"},
+		&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+	)
+
+	projectConfig := &Config{}
+	_ = projectConfig.UnmarshalYAML(node)
+	return projectConfig
+}
+
+// CreateProjectConfig saves Config to a new file, header is written as
+// a comment at the top of the file. It fails if the file already exists.
+func CreateProjectConfig(projectConfig *Config, filePath string, header string) error {
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		return fmt.Errorf("failed to create %s file, it already exists", filePath)
+	}
+
+	marshaledProjectConfig, err := projectConfig.MarshalYAML()
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %s", err)
+	}
+	if node, ok := marshaledProjectConfig.(*yaml.Node); ok {
+		node.HeadComment = header
+	}
+
+	if err := config.SaveConfigFile(marshaledProjectConfig, filePath); err != nil {
+		return fmt.Errorf("failed to save file in %s because of: %s", filePath, err)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+	projectConfig.Meta = config.Metadata{Path: absPath, Exists: true}
+
+	return nil
+}
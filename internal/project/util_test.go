@@ -4,6 +4,8 @@ import (
 	"os"
 	"path"
 	"testing"
+
+	"github.com/g2a-com/klio/internal/dependency"
 )
 
 func TestCreateDefaultProjectConfig(t *testing.T) {
@@ -69,3 +71,25 @@ func TestCreateDefaultProjectConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateProjectConfig(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "klio.yaml")
+
+	projectConfig := NewProjectConfig("https://example.com/registry.yaml")
+	projectConfig.SetDependency(dependency.Dependency{Name: "hello", Alias: "hello", Registry: "https://example.com/registry.yaml", Version: "*"}, "1.2.0")
+	if err := CreateProjectConfig(projectConfig, filePath, "Project commands."); err != nil {
+		t.Fatalf("CreateProjectConfig() error = %v", err)
+	}
+
+	want := "# Project commands.\ndefaultRegistry: https://example.com/registry.yaml\ndependencies:\n  hello:\n    version: ^1.2.0\n"
+	if got, _ := os.ReadFile(filePath); string(got) != want {
+		t.Errorf("CreateProjectConfig() wrote %q, want %q", got, want)
+	}
+	if !projectConfig.Meta.Exists || projectConfig.Meta.Path != filePath {
+		t.Errorf("CreateProjectConfig() set meta to %+v", projectConfig.Meta)
+	}
+
+	if err := CreateProjectConfig(NewProjectConfig(""), filePath, ""); err == nil {
+		t.Errorf("CreateProjectConfig() expected error for existing file")
+	}
+}
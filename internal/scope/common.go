@@ -1,11 +1,19 @@
 package scope
 
 import (
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/env"
 	"github.com/g2a-com/klio/internal/log"
 )
 
+const defaultInstallJobs = 4
+
 type Scope interface {
 	GetImplicitDependencies() []dependency.Dependency
 	InstallDependencies([]dependency.Dependency) ([]dependency.Dependency, []dependency.DependenciesIndexEntry, error)
@@ -15,28 +23,91 @@ type Scope interface {
 }
 
 func installDependencies(depsMgr *manager.Manager, toInstall []dependency.Dependency, installDir string) ([]dependency.Dependency, []dependency.DependenciesIndexEntry, error) {
-	var installedDeps []dependency.Dependency
-	var installedDepsIndex []dependency.DependenciesIndexEntry
+	installedDeps := make([]dependency.Dependency, len(toInstall))
+	installedDepsIndex := make([]dependency.DependenciesIndexEntry, len(toInstall))
+	errs := make([]error, len(toInstall))
+
+	depsMgr.StartProgress(len(toInstall))
 
-	for _, dep := range toInstall {
-		depIndexEntry, err := depsMgr.InstallDependency(&dep, installDir)
+	// install dependencies concurrently, keeping results in the original order
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	jobs := make(chan int)
+	for w := 0; w < getInstallJobs(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if failed.Load() {
+					continue
+				}
+				dep := toInstall[i]
+				depIndexEntry, err := depsMgr.InstallDependency(&dep, installDir)
+				if err != nil {
+					errs[i] = err
+					failed.Store(true)
+					continue
+				}
+				installedDeps[i] = dep
+				installedDepsIndex[i] = *depIndexEntry
+			}
+		}()
+	}
+	for i := range toInstall {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	depsMgr.StopProgress()
+
+	for _, err := range errs {
 		if err != nil {
 			return nil, nil, err
 		}
+	}
 
+	for _, dep := range installedDeps {
 		if dep.Alias == "" {
 			log.Infof("Installed %s@%s from %s", dep.Name, dep.Version, dep.Registry)
 		} else {
 			log.Infof("Installed %s@%s from %s as %s", dep.Name, dep.Version, dep.Registry, dep.Alias)
 		}
-
-		installedDeps = append(installedDeps, dep)
-		installedDepsIndex = append(installedDepsIndex, *depIndexEntry)
 	}
 
 	return installedDeps, installedDepsIndex, nil
 }
 
+// getInstallJobs returns maximum number of dependencies installed concurrently.
+func getInstallJobs() int {
+	jobsStr, exists := os.LookupEnv(env.KLIO_INSTALL_JOBS)
+	if !exists {
+		return defaultInstallJobs
+	}
+	jobs, err := strconv.Atoi(jobsStr)
+	if err != nil || jobs < 1 {
+		log.Warnf("Could not parse positive integer value of %s: %s", env.KLIO_INSTALL_JOBS, jobsStr)
+		return defaultInstallJobs
+	}
+	return jobs
+}
+
+// IsAutoInit checks whether project config files should be created implicitly
+// by installing commands, it is enabled with the KLIO_AUTO_INIT environment
+// variable.
+func IsAutoInit() bool {
+	autoInitStr, exists := os.LookupEnv(env.KLIO_AUTO_INIT)
+	if !exists {
+		return false
+	}
+	autoInit, err := strconv.ParseBool(autoInitStr)
+	if err != nil {
+		log.Warnf("Could not parse boolean value of %s, err: %s", env.KLIO_AUTO_INIT, err.Error())
+		return false
+	}
+	return autoInit
+}
+
 func removeDependencies(depsMgr *manager.Manager, toRemove []dependency.Dependency, installDir string) []dependency.Dependency {
 	var removedDeps []dependency.Dependency
 
@@ -47,11 +47,10 @@ func (g *global) validatePaths() error {
 
 func (g *global) initialize(ctx *context.CLIContext) error {
 	// initialize dependency manager
-	g.dependencyManager = manager.NewManager()
-	g.dependencyManager.DefaultRegistry = ctx.Config.DefaultRegistry
+	g.dependencyManager = manager.NewManagerForContext(*ctx)
 	installedCommands := g.dependencyManager.GetInstalledCommands(ctx.Paths)
 	for _, command := range installedCommands {
-		if ctx.Paths.IsGlobal(command.Path) {
+		if ctx.Paths.IsGlobal(command.InstallDir) && !command.IsLink() {
 			g.installedDeps = append(g.installedDeps, command.ToDependency())
 		}
 	}
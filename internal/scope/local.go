@@ -9,6 +9,7 @@ import (
 	"github.com/g2a-com/klio/internal/context"
 	"github.com/g2a-com/klio/internal/dependency"
 	"github.com/g2a-com/klio/internal/dependency/manager"
+	"github.com/g2a-com/klio/internal/log"
 	"github.com/g2a-com/klio/internal/project"
 	"github.com/spf13/afero"
 )
@@ -19,21 +20,24 @@ const (
 
 type local struct {
 	projectConfig     *project.Config
+	projectLock       *project.Lock
 	dependencyManager *manager.Manager
 	installedDeps     []dependency.Dependency
 	removedDeps       []dependency.Dependency
 	os                afero.Fs
 	projectConfigFile string
 	installDir        string
-	noInit            bool
+	autoInit          bool
 	noSave            bool
 }
 
-func NewLocal(ctx *context.CLIContext, noInit bool, noSave bool) (*local, error) {
+// NewLocal returns project scope. Project config file is created if it
+// doesn't exist and autoInit is set, otherwise an error is returned.
+func NewLocal(ctx *context.CLIContext, autoInit bool, noSave bool) (*local, error) {
 	l := &local{
 		projectConfigFile: ctx.Paths.ProjectConfigFile,
 		installDir:        ctx.Paths.ProjectInstallDir,
-		noInit:            noInit,
+		autoInit:          autoInit,
 		noSave:            noSave,
 		os:                afero.NewOsFs(),
 	}
@@ -68,25 +72,21 @@ func (l *local) validatePaths() error {
 func (l *local) initialize(ctx *context.CLIContext) error {
 	// look for config file
 	configFile, configFileErr := l.os.Stat(l.projectConfigFile)
-	if !l.noInit {
-		// make sure install dir exists
-		_ = l.os.MkdirAll(l.installDir, standardDirPermission)
-		// make sure if config file exists
-		if os.IsNotExist(configFileErr) {
-			_, err := project.CreateDefaultProjectConfig(l.projectConfigFile)
-			if err != nil {
-				return err
-			}
-		} else if configFileErr == nil && configFile.IsDir() {
-			return fmt.Errorf("can't create config file; path collision with a directory %s", l.projectConfigFile)
+	if os.IsNotExist(configFileErr) && l.autoInit {
+		if _, err := project.CreateDefaultProjectConfig(l.projectConfigFile); err != nil {
+			return err
 		}
+		log.Infof("Created %s", l.projectConfigFile)
 	} else if os.IsNotExist(configFileErr) {
-		return fmt.Errorf("%s not found; make sure it exists before running command with \"--no-init\"", ctx.Config.ProjectConfigFileName)
+		return fmt.Errorf(`%s not found; run "%s init" to create it`, ctx.Config.ProjectConfigFileName, ctx.Config.CommandName)
+	} else if configFileErr == nil && configFile.IsDir() {
+		return fmt.Errorf("can't create config file; path collision with a directory %s", l.projectConfigFile)
 	}
+	// make sure install dir exists
+	_ = l.os.MkdirAll(l.installDir, standardDirPermission)
 
 	// initialize dependency manager
-	l.dependencyManager = manager.NewManager()
-	l.dependencyManager.DefaultRegistry = ctx.Config.DefaultRegistry
+	l.dependencyManager = manager.NewManagerForContext(*ctx)
 
 	// load project config
 	var err error
@@ -94,12 +94,34 @@ func (l *local) initialize(ctx *context.CLIContext) error {
 	if err != nil {
 		return err
 	}
+	// registry chosen for the project takes precedence over the global one
+	if l.projectConfig.DefaultRegistry != "" {
+		l.dependencyManager.DefaultRegistry = l.projectConfig.DefaultRegistry
+	}
+	for registryURL, keys := range l.projectConfig.TrustedKeys {
+		l.dependencyManager.AddTrustedKeys(registryURL, keys)
+	}
+	l.dependencyManager.RequireChecksums = l.dependencyManager.RequireChecksums || l.projectConfig.RequireChecksums
+	l.dependencyManager.TrustOnFirstUse = l.dependencyManager.TrustOnFirstUse || l.projectConfig.TrustOnFirstUse
+
+	// load project lock
+	l.projectLock, err = project.LoadLock(ctx.Paths.ProjectLockFile)
+	if err != nil {
+		return fmt.Errorf("unable to load lock file %s: %s", ctx.Paths.ProjectLockFile, err)
+	}
+	l.dependencyManager.Locked = l.projectLock.Dependencies
 
 	return nil
 }
 
+// VerifyLock checks whether the lock file is in sync with the project config.
+func (l *local) VerifyLock() error {
+	return l.projectLock.Verify(l.projectConfig)
+}
+
 func (l *local) GetImplicitDependencies() []dependency.Dependency {
-	return l.projectConfig.Dependencies
+	// return a copy, so callers can't modify the project config by accident
+	return append([]dependency.Dependency{}, l.projectConfig.Dependencies...)
 }
 
 func (l *local) InstallDependencies(listOfCommands []dependency.Dependency) ([]dependency.Dependency, []dependency.DependenciesIndexEntry, error) {
@@ -107,33 +129,38 @@ func (l *local) InstallDependencies(listOfCommands []dependency.Dependency) ([]d
 		return nil, nil, fmt.Errorf("no dependencies provided for the project")
 	}
 
-	installedDeps, installedDepsEntries, err := installDependencies(l.dependencyManager, listOfCommands, l.installDir)
+	// use versions from the lock file whenever they satisfy requested ones
+	var requestedDeps, toInstall []dependency.Dependency
+	for _, dep := range listOfCommands {
+		dep.SetDefaults(l.dependencyManager.DefaultRegistry)
+		requestedDeps = append(requestedDeps, dep)
+		toInstall = append(toInstall, l.projectLock.Pin(dep))
+	}
+
+	installedDeps, installedDepsEntries, err := installDependencies(l.dependencyManager, toInstall, l.installDir)
 	if err != nil {
 		return nil, nil, err
 	}
 	l.installedDeps = installedDeps
 
 	if !l.noSave {
-		for _, installedDep := range l.installedDeps {
-			var idx int
-			var projectDep dependency.Dependency
-			for idx, projectDep = range l.projectConfig.Dependencies {
-				if projectDep.Alias == installedDep.Alias {
-					l.projectConfig.Dependencies[idx] = installedDep
-					break
-				}
-			}
-			if idx != len(l.projectConfig.Dependencies) {
-			} else {
-				l.projectConfig.Dependencies = append(l.projectConfig.Dependencies, installedDep)
-			}
+		// save requested version constraints, resolved versions go to the lock file
+		for i, requestedDep := range requestedDeps {
+			l.projectConfig.SetDependency(requestedDep, installedDeps[i].Version)
 		}
 
-		l.projectConfig.DefaultRegistry = l.dependencyManager.DefaultRegistry
+		if l.projectConfig.DefaultRegistry == "" {
+			l.projectConfig.DefaultRegistry = l.dependencyManager.DefaultRegistry
+		}
 
 		if err := project.SaveProjectConfig(l.projectConfig); err != nil {
 			return nil, nil, fmt.Errorf("unable to update dependencies in the %s file: %s", l.projectConfigFile, err)
 		}
+
+		l.projectLock.Update(installedDepsEntries, l.projectConfig)
+		if err := project.SaveLock(l.projectLock); err != nil {
+			return nil, nil, fmt.Errorf("unable to update the %s file: %s", l.projectLock.Meta.Path, err)
+		}
 	}
 
 	return installedDeps, installedDepsEntries, nil
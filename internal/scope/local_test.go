@@ -0,0 +1,80 @@
+package scope
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/g2a-com/klio/internal/archive"
+	"github.com/g2a-com/klio/internal/context"
+	"github.com/g2a-com/klio/internal/dependency"
+	"github.com/g2a-com/klio/internal/project"
+	"github.com/spf13/afero"
+)
+
+func TestInstallDependenciesUsesProjectDefaultRegistry(t *testing.T) {
+	commandDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(commandDir, "command.yaml"), []byte("apiVersion: klio/v1\nkind: Command\nbinPath: hello.sh\nversion: 1.0.0\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(commandDir, "hello.sh"), []byte("#!/bin/sh\n"), 0o755)
+	var commandArchive bytes.Buffer
+	if err := archive.CreateTarGz(afero.NewOsFs(), commandDir, &commandArchive, "hello.sh", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var registryServer *httptest.Server
+	registryServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/registry.yaml":
+			_, _ = fmt.Fprintf(w, "entries:\n  - name: hello\n    version: 1.0.0\n    url: %s/hello.tar.gz\n", registryServer.URL)
+		case "/hello.tar.gz":
+			_, _ = w.Write(commandArchive.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registryServer.Close()
+	projectRegistry := registryServer.URL + "/registry.yaml"
+
+	// project created with "init --registry"
+	projectDir := filepath.Join(t.TempDir(), "project")
+	_ = os.MkdirAll(projectDir, 0o755)
+	ctx := &context.CLIContext{
+		Config: context.CLIConfig{
+			CommandName:           "klio",
+			ProjectConfigFileName: "klio.yaml",
+			DefaultRegistry:       "https://global.invalid/registry.yaml",
+		},
+		Paths: context.Paths{
+			ProjectConfigFile: filepath.Join(projectDir, "klio.yaml"),
+			ProjectLockFile:   filepath.Join(projectDir, "klio.lock"),
+			ProjectInstallDir: filepath.Join(projectDir, ".klio"),
+			CacheDir:          filepath.Join(t.TempDir(), "cache"),
+		},
+	}
+	if err := project.CreateProjectConfig(project.NewProjectConfig(projectRegistry), ctx.Paths.ProjectConfigFile, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewLocal(ctx, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l.InstallDependencies([]dependency.Dependency{{Name: "hello", Version: "1.0.0"}}); err != nil {
+		t.Fatalf("InstallDependencies() error = %v", err)
+	}
+
+	projectConfig, err := project.LoadProjectConfig(ctx.Paths.ProjectConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projectConfig.DefaultRegistry != projectRegistry {
+		t.Errorf("defaultRegistry = %s, want %s", projectConfig.DefaultRegistry, projectRegistry)
+	}
+	if len(projectConfig.Dependencies) != 1 || projectConfig.Dependencies[0].Registry != projectRegistry {
+		t.Errorf("dependencies = %+v, want hello from %s", projectConfig.Dependencies, projectRegistry)
+	}
+}
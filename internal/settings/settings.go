@@ -0,0 +1,73 @@
+package settings
+
+import (
+	"time"
+
+	"github.com/g2a-com/klio/internal/config"
+)
+
+// Settings describes structure of the user config file (~/.klio/config.yaml).
+// It contains settings which are specific to the user, and as such mustn't be
+// stored in project config files.
+type Settings struct {
+	Meta       config.Metadata `yaml:"-"`
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Registries []Registry      `yaml:"registries,omitempty" validate:"dive"`
+	// Mirrors maps URL prefixes of registries and archives to URL prefixes of
+	// their mirrors, which are tried before the original URLs.
+	Mirrors map[string]string `yaml:"mirrors,omitempty" validate:"dive,keys,url,endkeys,url"`
+	// FallbackRegistries are tried in order if a registry can't be loaded.
+	FallbackRegistries []string `yaml:"fallbackRegistries,omitempty" validate:"dive,url"`
+	// RequireChecksums makes installation of commands without checksums in
+	// registries fail.
+	RequireChecksums bool `yaml:"requireChecksums,omitempty"`
+	// TrustOnFirstUse makes installation fail if the checksum of a command
+	// differs from the one recorded when it was downloaded for the first time.
+	TrustOnFirstUse bool `yaml:"trustOnFirstUse,omitempty"`
+	// Download configures timeouts and retries of HTTP requests.
+	Download *Download `yaml:"download,omitempty"`
+	// LockTimeout is the maximum time of waiting for other klio processes
+	// installing commands in the same directory (e.g. "30s" or "5m").
+	LockTimeout time.Duration `yaml:"lockTimeout,omitempty" validate:"gte=0"`
+	// ArchiveLimits override default limits of extracted archives.
+	ArchiveLimits *ArchiveLimits `yaml:"archiveLimits,omitempty"`
+}
+
+// ArchiveLimits restrict content of extracted archives. Zero values mean
+// that default limits are used.
+type ArchiveLimits struct {
+	MaxTotalSize int64 `yaml:"maxTotalSize,omitempty" validate:"gte=0"`
+	MaxFileSize  int64 `yaml:"maxFileSize,omitempty" validate:"gte=0"`
+	MaxEntries   int   `yaml:"maxEntries,omitempty" validate:"gte=0"`
+}
+
+// Download configures timeouts and retries of HTTP requests. Zero values
+// mean that defaults are used.
+type Download struct {
+	ConnectTimeout time.Duration `yaml:"connectTimeout,omitempty" validate:"gte=0"`
+	ReadTimeout    time.Duration `yaml:"readTimeout,omitempty" validate:"gte=0"`
+	Retries        *int          `yaml:"retries,omitempty" validate:"omitempty,gte=0"`
+}
+
+// Registry contains credentials used for requests to URLs under URL (both
+// registry indexes and archives with commands), and public keys trusted by
+// registries under URL. URLs have to use the same scheme and host, and their
+// paths have to start with the path of URL (see auth.HasURLPrefix).
+type Registry struct {
+	URL         string            `yaml:"url" validate:"required,url"`
+	Username    string            `yaml:"username,omitempty"`
+	Password    string            `yaml:"password,omitempty"`
+	Token       string            `yaml:"token,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	TrustedKeys []string          `yaml:"trustedKeys,omitempty"`
+}
+
+// Load reads user config file. Missing file results in empty settings.
+func Load(filePath string) (*Settings, error) {
+	settings := &Settings{}
+	if err := config.LoadConfigFile(settings, &settings.Meta, filePath); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
@@ -0,0 +1,166 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// legacyAlgorithm signs the whole message.
+	legacyAlgorithm = "Ed"
+	// hashedAlgorithm signs BLAKE2b-512 hash of the message.
+	hashedAlgorithm = "ED"
+
+	untrustedCommentPrefix = "untrusted comment:"
+	trustedCommentPrefix   = "trusted comment:"
+)
+
+// PublicKey is a minisign (ed25519) public key with its minisign identifier.
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// Signature is a detached minisign signature.
+type Signature struct {
+	Algorithm      string
+	KeyID          [8]byte
+	Signature      []byte
+	TrustedComment string
+	GlobalSig      []byte
+}
+
+// ParsePublicKey parses a public key. It accepts both content of minisign
+// .pub files and base64 encoded keys alone.
+func ParsePublicKey(text string) (*PublicKey, error) {
+	lines := getLines(text)
+	if len(lines) > 0 && strings.HasPrefix(lines[0], untrustedCommentPrefix) {
+		lines = lines[1:]
+	}
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("invalid public key format")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %s", err)
+	}
+	if len(data) != 2+8+ed25519.PublicKeySize || string(data[:2]) != legacyAlgorithm {
+		return nil, fmt.Errorf("unsupported public key")
+	}
+
+	key := &PublicKey{Key: ed25519.PublicKey(data[10:])}
+	copy(key.ID[:], data[2:10])
+
+	return key, nil
+}
+
+// ParseSignature parses a signature. It accepts both content of minisign
+// .minisig files and base64 encoded signatures alone (without trusted comment).
+func ParseSignature(text string) (*Signature, error) {
+	lines := getLines(text)
+	if len(lines) > 0 && strings.HasPrefix(lines[0], untrustedCommentPrefix) {
+		lines = lines[1:]
+	}
+	if len(lines) != 1 && len(lines) != 3 {
+		return nil, fmt.Errorf("invalid signature format")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %s", err)
+	}
+	if len(data) != 2+8+ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature length")
+	}
+
+	sig := &Signature{
+		Algorithm: string(data[:2]),
+		Signature: data[10:],
+	}
+	copy(sig.KeyID[:], data[2:10])
+	if sig.Algorithm != legacyAlgorithm && sig.Algorithm != hashedAlgorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+
+	if len(lines) == 3 {
+		if !strings.HasPrefix(lines[1], trustedCommentPrefix) {
+			return nil, fmt.Errorf("invalid signature format, trusted comment expected")
+		}
+		sig.TrustedComment = strings.TrimPrefix(strings.TrimPrefix(lines[1], trustedCommentPrefix), " ")
+		if sig.GlobalSig, err = base64.StdEncoding.DecodeString(lines[2]); err != nil {
+			return nil, fmt.Errorf("invalid signature encoding: %s", err)
+		}
+		if len(sig.GlobalSig) != ed25519.SignatureSize {
+			return nil, fmt.Errorf("invalid signature length")
+		}
+	}
+
+	return sig, nil
+}
+
+// Verify checks whether the signature of the message was made with one of
+// the trusted keys.
+func Verify(message io.Reader, signature string, trustedKeys []string) error {
+	sig, err := ParseSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	var key *PublicKey
+	for _, trustedKey := range trustedKeys {
+		k, err := ParsePublicKey(trustedKey)
+		if err != nil {
+			return fmt.Errorf("invalid trusted key %s: %s", trustedKey, err)
+		}
+		if k.ID == sig.KeyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("signature was made with an untrusted key %s", formatKeyID(sig.KeyID))
+	}
+
+	var signed []byte
+	if sig.Algorithm == hashedAlgorithm {
+		hash, _ := blake2b.New512(nil)
+		if _, err := io.Copy(hash, message); err != nil {
+			return err
+		}
+		signed = hash.Sum(nil)
+	} else if signed, err = io.ReadAll(message); err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key.Key, signed, sig.Signature) {
+		return fmt.Errorf("invalid signature")
+	}
+	if sig.GlobalSig != nil && !ed25519.Verify(key.Key, append(bytes.Clone(sig.Signature), sig.TrustedComment...), sig.GlobalSig) {
+		return fmt.Errorf("invalid signature of the trusted comment")
+	}
+
+	return nil
+}
+
+func getLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// formatKeyID returns key ID in the form displayed by minisign.
+func formatKeyID(id [8]byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id[:]))
+}
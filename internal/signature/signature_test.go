@@ -0,0 +1,71 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// generateKey returns a new key pair in the minisign format.
+func generateKey(t *testing.T, id string) (string, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(legacyAlgorithm+id), publicKey...)
+	return fmt.Sprintf("untrusted comment: minisign public key\n%s\n", base64.StdEncoding.EncodeToString(data)), privateKey
+}
+
+// sign returns a minisign signature of the message.
+func sign(privateKey ed25519.PrivateKey, id string, message string) string {
+	hash := blake2b.Sum512([]byte(message))
+	sig := ed25519.Sign(privateKey, hash[:])
+	comment := "timestamp:1700000000"
+	globalSig := ed25519.Sign(privateKey, append(append([]byte{}, sig...), comment...))
+	return fmt.Sprintf(
+		"untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append([]byte(hashedAlgorithm+id), sig...)),
+		comment,
+		base64.StdEncoding.EncodeToString(globalSig),
+	)
+}
+
+func TestVerify(t *testing.T) {
+	trustedKey, trustedPrivateKey := generateKey(t, "trusted1")
+	otherKey, _ := generateKey(t, "other123")
+	_, untrustedPrivateKey := generateKey(t, "untrustd")
+
+	message := "archive content"
+	validSig := sign(trustedPrivateKey, "trusted1", message)
+	trustedKeys := []string{otherKey, trustedKey}
+
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		wantErr   string
+	}{
+		{"Valid", message, validSig, ""},
+		{"ValidWithoutTrustedComment", message, strings.Split(validSig, "\n")[1], ""},
+		{"ModifiedMessage", message + "!", validSig, "invalid signature"},
+		{"ModifiedTrustedComment", message, strings.Replace(validSig, "timestamp", "timestamp:0", 1), "invalid signature of the trusted comment"},
+		{"UntrustedKey", message, sign(untrustedPrivateKey, "untrustd", message), "untrusted key"},
+		{"InvalidFormat", message, "not a signature", "invalid signature encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(strings.NewReader(tt.message), tt.signature, trustedKeys)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
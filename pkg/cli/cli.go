@@ -1,8 +1,12 @@
 package cli
 
 import (
+	stdcontext "context"
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/g2a-com/klio/internal/cmd/root"
 	"github.com/g2a-com/klio/internal/context"
@@ -39,15 +43,25 @@ func (cli *CLI) Execute() error {
 	}
 
 	cfg.ProjectConfigFileName = fmt.Sprintf("%s.yaml", cli.CommandName)
+	cfg.ProjectLockFileName = fmt.Sprintf("%s.lock", cli.CommandName)
 	cfg.InstallDirName = fmt.Sprintf(".%s", cli.CommandName)
 
 	ctx, err := context.Initialize(cfg)
 	if err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
+	// the first interrupt cancels waiting for locks and downloads, the next
+	// one terminates the process immediately
+	interruptCtx, stop := signal.NotifyContext(stdcontext.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-interruptCtx.Done()
+		stop()
+	}()
+	ctx.Context = interruptCtx
 	cmd := root.NewCommand(ctx)
 
-	if err := cmd.Execute(); err != nil {
+	if err := cmd.ExecuteContext(interruptCtx); err != nil {
 		return errors.New("CLI execution ended with error")
 	}
 